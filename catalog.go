@@ -0,0 +1,283 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// SatelliteApiData is one catalog record, keeping the Space-Track GP
+// metadata alongside the element set. Records either carry TLE lines or, for
+// OMM sources, the parsed mean elements.
+type SatelliteApiData struct {
+	ObjectName  string        `json:"OBJECT_NAME"`
+	ObjectID    string        `json:"OBJECT_ID"`
+	NoradCatID  CatalogNumber `json:"NORAD_CAT_ID"`
+	ObjectType  string        `json:"OBJECT_TYPE"`  // PAYLOAD, ROCKET BODY, DEBRIS or UNKNOWN
+	RCSSize     string        `json:"RCS_SIZE"`     // SMALL, MEDIUM or LARGE
+	CountryCode string        `json:"COUNTRY_CODE"` // Owner, e.g. US, PRC, CIS
+	LaunchDate  string        `json:"LAUNCH_DATE"`  // YYYY-MM-DD
+	DecayDate   string        `json:"DECAY_DATE"`   // YYYY-MM-DD, empty while in orbit
+	Epoch       string        `json:"EPOCH"`        // Element set epoch, ISO-8601 UTC
+	Period      CatalogFloat  `json:"PERIOD"`       // Minutes
+	Apoapsis    CatalogFloat  `json:"APOAPSIS"`     // Altitude in km
+	Periapsis   CatalogFloat  `json:"PERIAPSIS"`    // Altitude in km
+	TLE_1       string        `json:"TLE_LINE1"`
+	TLE_2       string        `json:"TLE_LINE2"`
+	OMM         *OMM          `json:"-"`
+}
+
+const (
+	ObjectTypePayload    = "PAYLOAD"
+	ObjectTypeRocketBody = "ROCKET BODY"
+	ObjectTypeDebris     = "DEBRIS"
+	ObjectTypeUnknown    = "UNKNOWN"
+)
+
+// Number that Space-Track sends as a string and CelesTrak as a number
+type CatalogFloat float64
+
+func (c *CatalogFloat) UnmarshalJSON(data []byte) error {
+	text := strings.Trim(string(data), `"`)
+	if text == "" || text == "null" {
+		*c = 0
+		return nil
+	}
+
+	value, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return fmt.Errorf("invalid number %s", data)
+	}
+	*c = CatalogFloat(value)
+	return nil
+}
+
+// NORAD catalog number, up to 9 digits. Space-Track sends it as a string and
+// CelesTrak as a number, so both are accepted.
+type CatalogNumber int
+
+func (c *CatalogNumber) UnmarshalJSON(data []byte) error {
+	text := strings.Trim(string(data), `"`)
+	if text == "" || text == "null" {
+		*c = 0
+		return nil
+	}
+
+	value, err := strconv.Atoi(text)
+	if err != nil || value < 0 || value > 999999999 {
+		return fmt.Errorf("invalid NORAD_CAT_ID %s", data)
+	}
+	*c = CatalogNumber(value)
+	return nil
+}
+
+func (c CatalogNumber) String() string {
+	return strconv.Itoa(int(c))
+}
+
+// loadCatalogFile reads Space-Track style JSON (with or without TLE lines),
+// CCSDS OMM files in XML or KVN and plain 2LE/3LE text, detected from the
+// content. Metadata the source does not carry is derived where possible.
+// Malformed sets in text catalogs are skipped and returned as rejected.
+func loadCatalogFile(path string) ([]SatelliteApiData, []RejectedSatellite, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return []SatelliteApiData{}, nil, err
+	}
+
+	satellitesData, rejected, err := parseCatalog(data, path)
+	if err != nil {
+		return []SatelliteApiData{}, nil, err
+	}
+
+	for i := range satellitesData {
+		satellitesData[i].completeMetadata()
+	}
+	return satellitesData, rejected, nil
+}
+
+func parseCatalog(data []byte, path string) ([]SatelliteApiData, []RejectedSatellite, error) {
+	trimmed := bytes.TrimSpace(data)
+	var records []OMM
+	var err error
+	switch {
+	case len(trimmed) == 0:
+		return []SatelliteApiData{}, nil, fmt.Errorf("%s is empty", path)
+	case trimmed[0] == '[' || trimmed[0] == '{':
+		satellitesData, err := parseCatalogJSON(trimmed)
+		return satellitesData, nil, err
+	case looksLikeTLEText(trimmed):
+		return ReadTLEText(bytes.NewReader(trimmed))
+	case trimmed[0] == '<':
+		records, err = ReadOMMXML(bytes.NewReader(trimmed))
+	default:
+		records, err = ReadOMMKVN(bytes.NewReader(trimmed))
+	}
+	if err != nil {
+		return []SatelliteApiData{}, nil, err
+	}
+	return catalogFromOMM(records), nil, nil
+}
+
+// Records with TLE_LINE1 and TLE_LINE2 use the TLE, the rest are read as OMM
+func parseCatalogJSON(data []byte) ([]SatelliteApiData, error) {
+	var objects []json.RawMessage
+	if data[0] == '{' {
+		objects = []json.RawMessage{data}
+	} else if err := json.Unmarshal(data, &objects); err != nil {
+		return []SatelliteApiData{}, err
+	}
+
+	satellitesData := make([]SatelliteApiData, 0, len(objects))
+	for i, object := range objects {
+		var record SatelliteApiData
+		if err := json.Unmarshal(object, &record); err != nil {
+			return []SatelliteApiData{}, fmt.Errorf("record %d: %w", i, err)
+		}
+
+		if record.TLE_1 == "" && record.TLE_2 == "" {
+			fields, err := jsonObjectFields(object)
+			if err != nil {
+				return []SatelliteApiData{}, fmt.Errorf("record %d: %w", i, err)
+			}
+			omm := ommFromFields(fields)
+			record.OMM = &omm
+		}
+		satellitesData = append(satellitesData, record)
+	}
+
+	return satellitesData, nil
+}
+
+// TLE text starts with line 1, or with a name line followed by line 1
+func looksLikeTLEText(data []byte) bool {
+	lines := strings.SplitN(string(data), "\n", 3)
+	if strings.HasPrefix(lines[0], "1 ") {
+		return true
+	}
+	return len(lines) > 1 && strings.HasPrefix(strings.TrimLeft(lines[1], " \t\r"), "1 ") &&
+		!strings.Contains(lines[0], "=")
+}
+
+// XML and KVN OMM carry catalog metadata as user defined parameters
+func catalogFromOMM(records []OMM) []SatelliteApiData {
+	satellitesData := make([]SatelliteApiData, len(records))
+	for i := range records {
+		fields := records[i].Fields
+		float := func(keyword string) CatalogFloat {
+			value, _ := strconv.ParseFloat(fields[keyword], 64)
+			return CatalogFloat(value)
+		}
+
+		satellitesData[i] = SatelliteApiData{
+			ObjectName:  records[i].ObjectName,
+			ObjectID:    records[i].ObjectID,
+			NoradCatID:  records[i].NoradCatID,
+			ObjectType:  fields["OBJECT_TYPE"],
+			RCSSize:     fields["RCS_SIZE"],
+			CountryCode: fields["COUNTRY_CODE"],
+			LaunchDate:  fields["LAUNCH_DATE"],
+			DecayDate:   fields["DECAY_DATE"],
+			Epoch:       records[i].Epoch,
+			Period:      float("PERIOD"),
+			Apoapsis:    float("APOAPSIS"),
+			Periapsis:   float("PERIAPSIS"),
+			OMM:         &records[i],
+		}
+	}
+	return satellitesData
+}
+
+// completeMetadata normalises the object type and fills in the epoch, period
+// and apsis altitudes from the mean elements when the source left them out
+func (r *SatelliteApiData) completeMetadata() {
+	r.ObjectType = normalizeObjectType(r.ObjectType, r.ObjectName)
+
+	var meanMotion, eccentricity float64
+	if r.OMM != nil {
+		meanMotion, eccentricity = r.OMM.MeanMotion, r.OMM.Eccentricity
+	} else if tle, err := ParseTLE(r.TLE_1, r.TLE_2); err == nil {
+		meanMotion, eccentricity = tle.MeanMotion, tle.Eccentricity
+		if r.Epoch == "" {
+			r.Epoch = tle.EpochTime().Format("2006-01-02T15:04:05.000000")
+		}
+	}
+	if meanMotion <= 0 {
+		return
+	}
+
+	// Semi-major axis from the mean motion in rad/s, using the WGS-72
+	// constants SGP4 is built on
+	n := meanMotion * 2 * math.Pi / 86400.0
+	a := math.Cbrt(wgs72Mu / (n * n))
+	if r.Period == 0 {
+		r.Period = CatalogFloat(1440.0 / meanMotion)
+	}
+	if r.Apoapsis == 0 && r.Periapsis == 0 {
+		r.Apoapsis = CatalogFloat(a*(1+eccentricity) - wgs72RadiusEarthKm)
+		r.Periapsis = CatalogFloat(a*(1-eccentricity) - wgs72RadiusEarthKm)
+	}
+}
+
+// Maps Space-Track and CelesTrak spellings onto the Space-Track values. When
+// the source has no type, the DEB and R/B suffixes in names are used.
+func normalizeObjectType(objectType, objectName string) string {
+	switch strings.ToUpper(strings.TrimSpace(objectType)) {
+	case "PAYLOAD", "PAY":
+		return ObjectTypePayload
+	case "ROCKET BODY", "R/B":
+		return ObjectTypeRocketBody
+	case "DEBRIS", "DEB":
+		return ObjectTypeDebris
+	case "":
+	default:
+		return ObjectTypeUnknown
+	}
+
+	name := strings.ToUpper(objectName)
+	switch {
+	case strings.HasSuffix(name, " DEB") || strings.Contains(name, " DEB "):
+		return ObjectTypeDebris
+	case strings.HasSuffix(name, " R/B") || strings.Contains(name, " R/B "):
+		return ObjectTypeRocketBody
+	}
+	return ObjectTypeUnknown
+}
+
+// Short label for reports
+func objectTypeLabel(objectType string) string {
+	switch objectType {
+	case ObjectTypePayload:
+		return "PAY"
+	case ObjectTypeRocketBody:
+		return "R/B"
+	case ObjectTypeDebris:
+		return "DEB"
+	}
+	return "UNK"
+}
+
+// newSatelliteFromRecord validates a catalog record before anything reaches
+// the propagator library
+func newSatelliteFromRecord(record SatelliteApiData) (Propagator, error) {
+	if record.OMM != nil {
+		if err := record.OMM.Validate(); err != nil {
+			return nil, err
+		}
+		return NewSgp4SatelliteFromOMM(record.OMM)
+	}
+
+	tle, err := ParseTLE(record.TLE_1, record.TLE_2)
+	if err != nil {
+		return nil, err
+	}
+	if record.NoradCatID != 0 && int(record.NoradCatID) != tle.CatalogNumber {
+		return nil, fmt.Errorf("NORAD_CAT_ID %s does not match TLE catalog number %d", record.NoradCatID, tle.CatalogNumber)
+	}
+
+	return NewSgp4Satellite(record.TLE_1, record.TLE_2)
+}
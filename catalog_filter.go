@@ -0,0 +1,58 @@
+package main
+
+import (
+	"strings"
+	"time"
+)
+
+// CatalogFilter selects the records that take part in a screening. Empty
+// lists match everything.
+type CatalogFilter struct {
+	ObjectTypes    []string
+	CountryCodes   []string
+	RCSSizes       []string
+	ExcludeDecayed bool
+	// Objects whose DECAY_DATE ends on or before this time count as decayed.
+	// The date has no time of day, so an object decaying later on the day of
+	// DecayedBefore is kept.
+	DecayedBefore time.Time
+}
+
+func (f CatalogFilter) matches(record SatelliteApiData) bool {
+	if !matchesAny(f.ObjectTypes, record.ObjectType) ||
+		!matchesAny(f.CountryCodes, record.CountryCode) ||
+		!matchesAny(f.RCSSizes, record.RCSSize) {
+		return false
+	}
+
+	if f.ExcludeDecayed && record.DecayDate != "" {
+		decay, err := time.Parse("2006-01-02", record.DecayDate)
+		if err == nil && !decay.AddDate(0, 0, 1).After(f.DecayedBefore) {
+			return false
+		}
+	}
+	return true
+}
+
+func matchesAny(allowed []string, value string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	for _, a := range allowed {
+		if strings.EqualFold(a, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// filterCatalog returns the matching records and how many were dropped
+func filterCatalog(satellitesData []SatelliteApiData, filter CatalogFilter) ([]SatelliteApiData, int) {
+	kept := []SatelliteApiData{}
+	for _, record := range satellitesData {
+		if filter.matches(record) {
+			kept = append(kept, record)
+		}
+	}
+	return kept, len(satellitesData) - len(kept)
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCatalogMetadata(t *testing.T) {
+	catalog := `[
+		{"OBJECT_NAME": "STARLINK-6042", "OBJECT_ID": "2023-067N", "NORAD_CAT_ID": "56700",
+		 "OBJECT_TYPE": "PAYLOAD", "RCS_SIZE": "LARGE", "COUNTRY_CODE": "US",
+		 "LAUNCH_DATE": "2023-05-19", "DECAY_DATE": null, "PERIOD": "95.84",
+		 "TLE_LINE1": "` + SatTwoLineOne + `", "TLE_LINE2": "` + SatTwoLineTwo + `"},
+		{"OBJECT_NAME": "SL-16 R/B", "OBJECT_ID": "1979-104", "NORAD_CAT_ID": 84232,
+		 "OBJECT_TYPE": "R/B", "DECAY_DATE": "2024-12-01",
+		 "TLE_LINE1": "` + SatOneLineOne + `", "TLE_LINE2": "` + SatOneLineTwo + `"},
+		{"OBJECT_NAME": "FENGYUN 1C DEB", "OBJECT_ID": "2023-171T",
+		 "TLE_LINE1": "` + SatThreeLineOne + `", "TLE_LINE2": "` + SatThreeLineTwo + `"}
+	]`
+
+	path := filepath.Join(t.TempDir(), "catalog.json")
+	assert.NoError(t, os.WriteFile(path, []byte(catalog), 0o644))
+
+	records, _, err := loadCatalogFile(path)
+	assert.NoError(t, err)
+	assert.Len(t, records, 3)
+
+	assert.Equal(t, ObjectTypePayload, records[0].ObjectType)
+	assert.Equal(t, "LARGE", records[0].RCSSize)
+	assert.Equal(t, "2023-05-19", records[0].LaunchDate)
+	assert.Equal(t, CatalogFloat(95.84), records[0].Period)
+	assert.Equal(t, "2025-01-11T02:52:53.932224", records[0].Epoch)
+	assert.InDelta(t, 560, float64(records[0].Apoapsis), 20)
+
+	assert.Equal(t, ObjectTypeRocketBody, records[1].ObjectType)
+	assert.InDelta(t, 1440/3.09154996, float64(records[1].Period), 1e-9)
+	assert.Greater(t, float64(records[1].Apoapsis), float64(records[1].Periapsis))
+
+	// No OBJECT_TYPE, inferred from the name
+	assert.Equal(t, ObjectTypeDebris, records[2].ObjectType)
+
+	kept, excluded := filterCatalog(records, CatalogFilter{
+		ExcludeDecayed: true,
+		DecayedBefore:  time.Date(2025, 1, 12, 0, 0, 0, 0, time.UTC),
+	})
+	assert.Equal(t, 1, excluded)
+	assert.Len(t, kept, 2)
+
+	kept, _ = filterCatalog(records, CatalogFilter{ObjectTypes: []string{ObjectTypeDebris}})
+	assert.Len(t, kept, 1)
+	assert.Equal(t, "2023-171T", kept[0].ObjectID)
+
+	pairs := []OutPair{{Sat1ID: 0, Sat2ID: 2}}
+	labelPairs(pairs, records)
+	assert.Equal(t, "PAY-DEB", pairs[0].label())
+}
+
+func TestExcludeDecayedDayBoundary(t *testing.T) {
+	start := time.Date(2025, 1, 12, 18, 0, 0, 0, time.UTC)
+	filter := CatalogFilter{ExcludeDecayed: true, DecayedBefore: start}
+
+	// DECAY_DATE has no time of day, the object may still be up at start
+	assert.True(t, filter.matches(SatelliteApiData{DecayDate: "2025-01-12"}))
+	assert.True(t, filter.matches(SatelliteApiData{DecayDate: "2025-01-13"}))
+	assert.False(t, filter.matches(SatelliteApiData{DecayDate: "2025-01-11"}))
+
+	// Down by midnight at the end of the decay day
+	filter.DecayedBefore = time.Date(2025, 1, 13, 0, 0, 0, 0, time.UTC)
+	assert.False(t, filter.matches(SatelliteApiData{DecayDate: "2025-01-12"}))
+}
+
+func TestCatalogMetadataFromOMMUserDefined(t *testing.T) {
+	xml := `<ndm><omm><body><segment><metadata>
+		<OBJECT_NAME>STARLINK-6042</OBJECT_NAME><OBJECT_ID>2023-067N</OBJECT_ID>
+		</metadata><data><meanElements>
+		<EPOCH>2025-01-11T02:52:53.921824</EPOCH><MEAN_MOTION>15.02525502</MEAN_MOTION>
+		<ECCENTRICITY>.0001256</ECCENTRICITY><INCLINATION>43.0052</INCLINATION>
+		<RA_OF_ASC_NODE>50.6716</RA_OF_ASC_NODE><ARG_OF_PERICENTER>262.8432</ARG_OF_PERICENTER>
+		<MEAN_ANOMALY>97.2268</MEAN_ANOMALY></meanElements>
+		<userDefinedParameters>
+		<USER_DEFINED parameter="OBJECT_TYPE">PAYLOAD</USER_DEFINED>
+		<USER_DEFINED parameter="COUNTRY_CODE">US</USER_DEFINED>
+		</userDefinedParameters></data></segment></body></omm></ndm>`
+
+	path := filepath.Join(t.TempDir(), "catalog.xml")
+	assert.NoError(t, os.WriteFile(path, []byte(xml), 0o644))
+
+	records, _, err := loadCatalogFile(path)
+	assert.NoError(t, err)
+	assert.Len(t, records, 1)
+	assert.Equal(t, ObjectTypePayload, records[0].ObjectType)
+	assert.Equal(t, "US", records[0].CountryCode)
+	assert.Equal(t, "2025-01-11T02:52:53.921824", records[0].Epoch)
+	assert.InDelta(t, 1440/15.02525502, float64(records[0].Period), 1e-9)
+}
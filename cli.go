@@ -0,0 +1,481 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+const defaultCatalogPath = "satellites-api.json"
+
+const usage = `Usage: spacetrace <command> [flags]
+
+Commands:
+  screen     screen the whole catalog for close approaches (default)
+  propagate  print the state of one satellite over the window
+  pair       find the closest approaches between two satellites
+  montecarlo estimate the collision probability of a pair by sampling
+  validate   check tier one against a brute force screening
+
+Run "spacetrace <command> -h" for the flags of a command.
+`
+
+// run dispatches a command line. Flags without a command run a screening, as
+// the program did before it had commands.
+func run(args []string, stdout, stderr io.Writer) error {
+	command := "screen"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
+	}
+
+	switch command {
+	case "screen":
+		return runScreen(args, stdout, stderr)
+	case "propagate":
+		return runPropagate(args, stdout, stderr)
+	case "pair":
+		return runPair(args, stdout, stderr)
+	case "montecarlo":
+		return runMonteCarlo(args, stdout, stderr)
+	case "validate":
+		return runValidate(args, stdout, stderr)
+	case "help":
+		fmt.Fprint(stdout, usage)
+		return nil
+	}
+	return fmt.Errorf("unknown command %q\n\n%s", command, usage)
+}
+
+// Flags shared by every command. Flags given on the command line override
+// the config file, which overrides the defaults.
+type commandFlags struct {
+	config   string
+	start    string
+	duration time.Duration
+	step     time.Duration
+	catalog  string
+	output   string
+	count    int
+	from     string
+	to       string
+	// Comma separated catalog filter lists
+	objectTypes  string
+	countryCodes string
+	rcsSizes     string
+}
+
+func addCommandFlags(fs *flag.FlagSet, defaults Config) *commandFlags {
+	f := &commandFlags{}
+	fs.StringVar(&f.config, "config", "", "YAML or JSON config file")
+	fs.StringVar(&f.start, "start", defaults.Window.Start, "start of the window, ISO-8601 UTC")
+	fs.DurationVar(&f.duration, "duration", defaults.Window.Duration, "length of the window")
+	fs.DurationVar(&f.step, "step", defaults.Window.Step, "time between samples")
+	fs.StringVar(&f.catalog, "catalog", defaults.Catalog, "catalog file (JSON, OMM XML/KVN or 2LE/3LE text)")
+	fs.StringVar(&f.objectTypes, "object-types", strings.Join(defaults.CatalogFilter.ObjectTypes, ","), "screen only these object types, comma separated")
+	fs.StringVar(&f.countryCodes, "country-codes", strings.Join(defaults.CatalogFilter.CountryCodes, ","), "screen only objects of these owners, comma separated")
+	fs.StringVar(&f.rcsSizes, "rcs-sizes", strings.Join(defaults.CatalogFilter.RCSSizes, ","), "screen only these RCS sizes, comma separated")
+	fs.StringVar(&f.output, "output", defaults.Output.Path, "write results to this file instead of stdout")
+	fs.IntVar(&f.count, "count", defaults.Output.Count, "number of closest pairs or approaches to report")
+	fs.StringVar(&f.from, "from", defaults.Output.From, "report only events from this time, ISO-8601 UTC")
+	fs.StringVar(&f.to, "to", defaults.Output.To, "report only events before this time, ISO-8601 UTC")
+	return f
+}
+
+// resolve builds the validated config of the run
+func (f *commandFlags) resolve(fs *flag.FlagSet, defaults Config) (Config, error) {
+	config := defaults
+	if f.config != "" {
+		if err := loadConfig(f.config, &config); err != nil {
+			return Config{}, err
+		}
+	}
+
+	fs.Visit(func(set *flag.Flag) {
+		switch set.Name {
+		case "start":
+			config.Window.Start = f.start
+		case "duration":
+			config.Window.Duration = f.duration
+		case "step":
+			config.Window.Step = f.step
+		case "catalog":
+			config.Catalog = f.catalog
+		case "object-types":
+			config.CatalogFilter.ObjectTypes = splitList(f.objectTypes)
+		case "country-codes":
+			config.CatalogFilter.CountryCodes = splitList(f.countryCodes)
+		case "rcs-sizes":
+			config.CatalogFilter.RCSSizes = splitList(f.rcsSizes)
+		case "output":
+			config.Output.Path = f.output
+		case "count":
+			config.Output.Count = f.count
+		case "from":
+			config.Output.From = f.from
+		case "to":
+			config.Output.To = f.to
+		}
+	})
+
+	// The start may fall on a leap second only the file knows about
+	if config.LeapSeconds != "" {
+		if err := useLeapSecondsFile(config.LeapSeconds); err != nil {
+			return Config{}, fmt.Errorf("config leap_seconds: %w", err)
+		}
+	}
+	if err := config.validate(); err != nil {
+		return Config{}, err
+	}
+	return config.resolved(), nil
+}
+
+// splitList reads a comma separated flag, empty meaning no values
+func splitList(text string) []string {
+	values := []string{}
+	for _, value := range strings.Split(text, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// Opens the output file, or stdout when none was given. The returned close
+// function is always safe to call.
+func openOutput(path string, stdout io.Writer) (io.Writer, func() error, error) {
+	if path == "" {
+		return stdout, func() error { return nil }, nil
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return file, file.Close, nil
+}
+
+// recordConfig logs the resolved config and writes it to
+// output.resolved_config when set
+func recordConfig(config Config, log io.Writer) error {
+	fmt.Fprintln(log, "Resolved config:")
+	if err := writeConfig(log, config); err != nil {
+		return err
+	}
+
+	if config.Output.ResolvedConfig == "" {
+		return nil
+	}
+	file, err := os.Create(config.Output.ResolvedConfig)
+	if err != nil {
+		return err
+	}
+	if err := writeConfig(file, config); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func runScreen(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("screen", flag.ContinueOnError)
+	defaults := defaultConfig()
+	flags := addCommandFlags(fs, defaults)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	config, err := flags.resolve(fs, defaults)
+	if err != nil {
+		return err
+	}
+	if err := recordConfig(config, stderr); err != nil {
+		return err
+	}
+
+	catalog, err := loadSatellites(config.Catalog, config.catalogFilter(), stderr)
+	if err != nil {
+		return err
+	}
+
+	out, closeOutput, err := openOutput(config.Output.Path, stdout)
+	if err != nil {
+		return err
+	}
+	screen(catalog, config, out, stderr)
+	return closeOutput()
+}
+
+func runPropagate(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("propagate", flag.ContinueOnError)
+	defaults := defaultConfig()
+	defaults.Window.Duration = 90 * time.Minute
+	defaults.Window.Step = time.Minute
+	flags := addCommandFlags(fs, defaults)
+	id := fs.String("id", "", "NORAD catalog number, international designator or name")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	config, err := flags.resolve(fs, defaults)
+	if err != nil {
+		return err
+	}
+	if err := recordConfig(config, stderr); err != nil {
+		return err
+	}
+	if *id == "" {
+		return fmt.Errorf("-id is required")
+	}
+
+	window := config.window()
+	catalog, err := loadSatellites(config.Catalog, config.catalogFilter(), stderr)
+	if err != nil {
+		return err
+	}
+	index, err := catalog.find(*id)
+	if err != nil {
+		return err
+	}
+
+	out, closeOutput, err := openOutput(config.Output.Path, stdout)
+	if err != nil {
+		return err
+	}
+
+	// TEME position in km and velocity in km/s
+	satellite := catalog.satellites[index]
+	fmt.Fprintln(out, "time,julian_date,x,y,z,vx,vy,vz")
+	for _, julianTime := range window.julianTimes() {
+		state, err := satellite.stateAtTime(julianTime)
+		if err != nil {
+			// Failed samples are left out of the CSV
+			fmt.Fprintln(stderr, formatJulianDate(julianTime), err)
+			continue
+		}
+		fmt.Fprintf(out, "%s,%.8f,%.6f,%.6f,%.6f,%.9f,%.9f,%.9f\n", formatJulianDate(julianTime), julianTime.float(),
+			state.Position.X, state.Position.Y, state.Position.Z,
+			state.Velocity.X, state.Velocity.Y, state.Velocity.Z)
+	}
+	return closeOutput()
+}
+
+func runPair(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("pair", flag.ContinueOnError)
+	defaults := defaultConfig()
+	defaults.Window.Step = time.Minute
+	defaults.Output.Count = 10
+	flags := addCommandFlags(fs, defaults)
+	sat1 := fs.String("sat1", "", "first satellite")
+	sat2 := fs.String("sat2", "", "second satellite")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	config, err := flags.resolve(fs, defaults)
+	if err != nil {
+		return err
+	}
+	if err := recordConfig(config, stderr); err != nil {
+		return err
+	}
+	_, _, approaches, err := pairApproaches(config, *sat1, *sat2, stderr)
+	if err != nil {
+		return err
+	}
+
+	out, closeOutput, err := openOutput(config.Output.Path, stdout)
+	if err != nil {
+		return err
+	}
+	for _, approach := range approaches {
+		fmt.Fprintln(out, formatJulianDate(approach.JulianTime), approach.JulianTime.float(), approach.Distance,
+			approach.Miss.Radial, approach.Miss.InTrack, approach.Miss.CrossTrack, approach.RelativeSpeed, approach.ApproachAngle, approach.Pc)
+	}
+	return closeOutput()
+}
+
+func runMonteCarlo(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("montecarlo", flag.ContinueOnError)
+	defaults := defaultConfig()
+	defaults.Window.Step = time.Minute
+	defaults.Output.Count = 1
+	flags := addCommandFlags(fs, defaults)
+	sat1 := fs.String("sat1", "", "first satellite")
+	sat2 := fs.String("sat2", "", "second satellite")
+	samples := fs.Int("samples", 10000, "dispersed samples of each event")
+	seed := fs.Int64("seed", 1, "seed for the dispersions")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if !dispersibleBackend {
+		return fmt.Errorf("montecarlo needs the native SGP4 propagator to disperse the element sets, build without -tags spacetrack")
+	}
+
+	config, err := flags.resolve(fs, defaults)
+	if err != nil {
+		return err
+	}
+	if err := recordConfig(config, stderr); err != nil {
+		return err
+	}
+	if *samples <= 0 {
+		return fmt.Errorf("-samples must be positive")
+	}
+	satellite1, satellite2, approaches, err := pairApproaches(config, *sat1, *sat2, stderr)
+	if err != nil {
+		return err
+	}
+
+	out, closeOutput, err := openOutput(config.Output.Path, stdout)
+	if err != nil {
+		return err
+	}
+	for _, approach := range approaches {
+		result, err := monteCarloPcWithWorkerPool(satellite1, satellite2, approach, *samples, *seed, config.TierTwo.Window.Seconds(), config.TierTwo, config.Pc)
+		if err != nil {
+			closeOutput()
+			return err
+		}
+		fmt.Fprintln(out, formatJulianDate(approach.JulianTime), approach.Distance, approach.Pc,
+			result.Pc, result.Low, result.High, result.Hits, result.Samples, result.Failed)
+	}
+	return closeOutput()
+}
+
+// pairApproaches finds the closest approaches of two satellites over the
+// window with their collision probability, ranked and cut to output.count.
+// Only the approaches from output.from up to output.to are kept.
+func pairApproaches(config Config, sat1, sat2 string, log io.Writer) (Propagator, Propagator, []MinDistancePoint, error) {
+	if sat1 == "" || sat2 == "" {
+		return nil, nil, nil, fmt.Errorf("-sat1 and -sat2 are required")
+	}
+
+	catalog, err := loadSatellites(config.Catalog, config.catalogFilter(), log)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	index1, err := catalog.find(sat1)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	index2, err := catalog.find(sat2)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	satellite1, satellite2 := catalog.satellites[index1], catalog.satellites[index2]
+
+	approaches, failures := closeApproaches(satellite1, satellite2, config.window(), config.TierTwo.Tolerance.Seconds())
+	if failures.total() > 0 {
+		fmt.Fprintf(log, "%d samples failed to propagate (%s), first at %s: %v\n",
+			failures.total(), failures.reason(), failures.FirstTime, failures.FirstError)
+	}
+	if from, to, ok := config.reportRange(); ok {
+		inRange := []MinDistancePoint{}
+		for _, approach := range approaches {
+			if approach.JulianTime.within(from, to) {
+				inRange = append(inRange, approach)
+			}
+		}
+		approaches = inRange
+	}
+	for i, approach := range approaches {
+		approaches[i].Pc, err = eventPc(approach, config.Pc)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	sort.Slice(approaches, func(i, j int) bool {
+		if config.Output.RankBy == RankByPc {
+			return pcRanksBefore(approaches[i].Pc, approaches[j].Pc)
+		}
+		return approaches[i].Distance < approaches[j].Distance
+	})
+	if len(approaches) > config.Output.Count {
+		approaches = approaches[:config.Output.Count]
+	}
+	return satellite1, satellite2, approaches, nil
+}
+
+func runValidate(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("validate", flag.ContinueOnError)
+	defaults := defaultConfig()
+	flags := addCommandFlags(fs, defaults)
+	sample := fs.Int("sample", 2000, "screen a random sub-catalog of this many satellites, 0 for all")
+	seed := fs.Int64("seed", 1, "seed for the sub-catalog sample")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	config, err := flags.resolve(fs, defaults)
+	if err != nil {
+		return err
+	}
+	if err := recordConfig(config, stderr); err != nil {
+		return err
+	}
+
+	catalog, err := loadSatellites(config.Catalog, config.catalogFilter(), stderr)
+	if err != nil {
+		return err
+	}
+	if *sample > 0 {
+		catalog = catalog.sample(*sample, *seed)
+	}
+
+	times := config.window().julianTimes()
+	ephemeris := buildSatLocations(catalog.satellites, times, config.Ephemeris, stderr)
+	var expected, actual [][]SatPair
+	if config.TierOne.Swept {
+		expected = bruteForceSweptCollisionsWithWorkerPool(len(times), len(catalog.satellites), ephemeris, times, config.TierOne)
+		actual = sweptCollisionsWithWorkerPool(len(times), len(catalog.satellites), ephemeris, times, config.TierOne)
+	} else {
+		expected = bruteForceCollisionsWithWorkerPool(len(times), len(catalog.satellites), ephemeris, config.TierOne)
+		actual = tierOneCollisionsWithWorkerPool(len(times), len(catalog.satellites), ephemeris, config.TierOne, stderr)
+	}
+	discrepancies := comparePairs(expected, actual)
+
+	out, closeOutput, err := openOutput(config.Output.Path, stdout)
+	if err != nil {
+		return err
+	}
+
+	totalExpected, totalMissed, totalExtra := 0, 0, 0
+	for _, pairs := range expected {
+		totalExpected += len(pairs)
+	}
+	for _, d := range discrepancies {
+		totalMissed += len(d.Missed)
+		totalExtra += len(d.Extra)
+		fmt.Fprintln(out, "time", d.TimeIndex, formatJulianDate(times[d.TimeIndex]), "expected", d.Expected, "missed", len(d.Missed), "extra", len(d.Extra))
+		for _, pair := range d.Missed {
+			fmt.Fprintln(out, "  missed", catalog.satellites[pair.ID1].satelliteID(), catalog.satellites[pair.ID2].satelliteID())
+		}
+		for _, pair := range d.Extra {
+			fmt.Fprintln(out, "  extra", catalog.satellites[pair.ID1].satelliteID(), catalog.satellites[pair.ID2].satelliteID())
+		}
+	}
+	fmt.Fprintln(out, "Validated", len(catalog.satellites), "satellites at", len(times), "times:",
+		totalExpected, "pairs expected,", totalMissed, "missed,", totalExtra, "extra")
+
+	if err := closeOutput(); err != nil {
+		return err
+	}
+	if len(discrepancies) > 0 {
+		return fmt.Errorf("tier one differs from brute force at %d of %d times", len(discrepancies), len(times))
+	}
+	return nil
+}
+
+// closeApproaches finds every closest approach over the window, scanning
+// the range rate at the window step
+func closeApproaches(sat1, sat2 Propagator, window ScreeningWindow, toleranceSeconds float64) ([]MinDistancePoint, PropagationFailures) {
+	end := julianDateAddSeconds(window.Start, window.Duration.Seconds())
+	return findCloseApproaches(sat1, sat2, window.Start, end, window.Step.Seconds(), toleranceSeconds)
+}
+
+func formatJulianDate(julianDate JulianDate) string {
+	return julianDateToISO(julianDate, 6)
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeTestCatalog(t *testing.T) string {
+	text := "STARLINK-6042\n" + SatTwoLineOne + "\n" + SatTwoLineTwo + "\n" +
+		"STARLINK-10059\n" + SatThreeLineOne + "\n" + SatThreeLineTwo + "\n"
+
+	path := filepath.Join(t.TempDir(), "catalog.txt")
+	assert.NoError(t, os.WriteFile(path, []byte(text), 0o644))
+	return path
+}
+
+func TestScreeningWindowTimes(t *testing.T) {
+	start, err := isoToJulianDate("2025-01-12T00:00:00Z")
+	assert.NoError(t, err)
+
+	times := ScreeningWindow{Start: start, Duration: 24 * time.Hour, Step: 4 * time.Minute}.julianTimes()
+	assert.Len(t, times, 360)
+	assert.Equal(t, createJulianDate(2025, 1, 12, 0, 0, 0), times[0])
+	assert.Equal(t, "2025-01-12T23:56:00.000000Z", times[359].String())
+}
+
+func TestScreeningWindowAcrossLeapSecond(t *testing.T) {
+	config := defaultConfig()
+	config.Window.Start = "2016-12-31T12:00:00Z"
+	config.Window.Duration = 24 * time.Hour
+	config.Window.Step = 4 * time.Hour
+	assert.Equal(t, "2016-12-31T12:00:00.000000Z", config.resolved().Window.Start)
+
+	// Samples are a step of SI time apart, so after the leap second they
+	// fall a second earlier on the clock
+	times := config.window().julianTimes()
+	assert.Equal(t, "2016-12-31T12:00:00.000000Z", times[0].String())
+	assert.Equal(t, "2016-12-31T16:00:00.000000Z", times[1].String())
+	assert.Equal(t, "2017-01-01T03:59:59.000000Z", times[4].String())
+	for i := 1; i < len(times); i++ {
+		assert.InDelta(t, 4*3600, differenceInSeconds(times[i-1], times[i]), 1e-6)
+	}
+
+	config.Window.Start = "2016-12-31T23:59:60Z"
+	config.Window.Step = time.Minute
+	assert.NoError(t, config.validate())
+	assert.Equal(t, "2016-12-31T23:59:60.000000Z", config.window().Start.String())
+}
+
+func TestScreenCommandLogsToStderr(t *testing.T) {
+	var out, log bytes.Buffer
+	err := run([]string{"screen", "-catalog", writeTestCatalog(t),
+		"-start", "2025-01-12T18:00:00Z", "-duration", "2h"}, &out, &log)
+	assert.NoError(t, err)
+
+	// Only the pairs go to stdout
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Len(t, lines, 3)
+	for _, line := range lines {
+		assert.Len(t, strings.Fields(line), 13, line)
+	}
+	assert.Contains(t, log.String(), "Resolved config:")
+	assert.Contains(t, log.String(), "Total time:")
+}
+
+func TestPairCommandFindsCloseApproach(t *testing.T) {
+	var out bytes.Buffer
+	err := run([]string{"pair", "-catalog", writeTestCatalog(t), "-sat1", "56700", "-sat2", "STARLINK-10059",
+		"-start", "2025-01-12T18:00:00Z", "-duration", "2h", "-count", "1"}, &out, io.Discard)
+	assert.NoError(t, err)
+
+	fields := strings.Fields(out.String())
+	assert.Len(t, fields, 9)
+	assert.True(t, strings.HasPrefix(fields[0], "2025-01-12T19:11:"), fields[0])
+	assert.Equal(t, "0.15", fields[2][:4])
+
+	// The miss components make up the distance
+	values := make([]float64, len(fields)-1)
+	for i, field := range fields[1:] {
+		values[i], err = strconv.ParseFloat(field, 64)
+		assert.NoError(t, err)
+	}
+	assert.InDelta(t, values[1], math.Sqrt(values[2]*values[2]+values[3]*values[3]+values[4]*values[4]), 1e-9)
+}
+
+func TestPropagateCommand(t *testing.T) {
+	var out bytes.Buffer
+	err := run([]string{"propagate", "-catalog", writeTestCatalog(t), "-id", "2023-067N",
+		"-start", "2025-01-12T00:00:00Z", "-duration", "10m", "-step", "5m"}, &out, io.Discard)
+	assert.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Len(t, lines, 3)
+	assert.True(t, strings.HasPrefix(lines[1], "2025-01-12T00:00:00.000000Z,"), lines[1])
+}
+
+func TestEveryCommandRecordsConfig(t *testing.T) {
+	catalog := writeTestCatalog(t)
+	for _, args := range [][]string{
+		{"screen"},
+		{"propagate", "-id", "56700"},
+		{"pair", "-sat1", "56700", "-sat2", "STARLINK-10059"},
+		{"validate"},
+	} {
+		dir := t.TempDir()
+		resolved := filepath.Join(dir, "resolved.yaml")
+		configPath := filepath.Join(dir, "config.yaml")
+		assert.NoError(t, os.WriteFile(configPath, []byte("output:\n  resolved_config: "+resolved+"\n"), 0o644))
+
+		var log bytes.Buffer
+		args = append(args, "-config", configPath, "-catalog", catalog,
+			"-start", "2025-01-12T18:00:00Z", "-duration", "30m", "-step", "1m")
+		assert.NoError(t, run(args, io.Discard, &log), args[0])
+		assert.Contains(t, log.String(), "Resolved config:", args[0])
+
+		var config Config
+		assert.NoError(t, loadConfig(resolved, &config), args[0])
+		assert.Equal(t, "2025-01-12T18:00:00.000000Z", config.Window.Start, args[0])
+	}
+}
+
+func TestCatalogFilterFlags(t *testing.T) {
+	catalog := writeTestCatalog(t)
+	args := []string{"propagate", "-catalog", catalog, "-id", "56700", "-duration", "10m"}
+
+	var log bytes.Buffer
+	assert.NoError(t, run(append(args, "-object-types", "unknown, debris", "-rcs-sizes", ""), io.Discard, &log))
+	assert.Contains(t, log.String(), "object_types:\n    - unknown\n    - debris\n")
+
+	// Text catalogs have no owner and no type the names tell, so neither
+	// filter leaves a record
+	assert.ErrorContains(t, run(append(args, "-country-codes", "US"), io.Discard, io.Discard), "no satellites")
+	assert.ErrorContains(t, run(append(args, "-object-types", ObjectTypePayload), io.Discard, io.Discard), "no satellites")
+}
+
+func TestCommandsReportTimeRange(t *testing.T) {
+	catalog := writeTestCatalog(t)
+
+	// The pair meets at 18:23, 19:11 and 19:58
+	var out bytes.Buffer
+	err := run([]string{"screen", "-catalog", catalog, "-start", "2025-01-12T18:00:00Z", "-duration", "2h",
+		"-from", "2025-01-12T19:00:00Z", "-to", "2025-01-12T19:30:00Z"}, &out, io.Discard)
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Len(t, lines, 1)
+	assert.True(t, strings.HasPrefix(strings.Fields(lines[0])[5], "2025-01-12T19:11:"), lines[0])
+
+	out.Reset()
+	err = run([]string{"pair", "-catalog", catalog, "-sat1", "56700", "-sat2", "58247",
+		"-start", "2025-01-12T18:00:00Z", "-duration", "2h", "-from", "2025-01-12T19:30:00Z"}, &out, io.Discard)
+	assert.NoError(t, err)
+	lines = strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Len(t, lines, 1)
+	assert.True(t, strings.HasPrefix(lines[0], "2025-01-12T19:58:"), lines[0])
+}
+
+func TestCommandErrors(t *testing.T) {
+	var out bytes.Buffer
+	assert.ErrorContains(t, run([]string{"orbit"}, &out, io.Discard), "unknown command")
+	assert.ErrorContains(t, run([]string{"screen", "-start", "12/01/2025"}, &out, io.Discard), "ISO-8601")
+	assert.ErrorContains(t, run([]string{"pair", "-catalog", writeTestCatalog(t), "-sat1", "56700", "-sat2", "1"}, &out, io.Discard), "not found")
+}
+
+func TestValidateCommand(t *testing.T) {
+	var out bytes.Buffer
+	err := run([]string{"validate", "-catalog", writeTestCatalog(t),
+		"-start", "2025-01-12T18:00:00Z", "-duration", "2h", "-step", "1m"}, &out, io.Discard)
+	assert.NoError(t, err)
+	assert.Contains(t, out.String(), "Validated 2 satellites at 120 times:")
+	assert.Contains(t, out.String(), "0 missed, 0 extra")
+}
+
+func TestComparePairs(t *testing.T) {
+	expected := [][]SatPair{{{ID1: 0, ID2: 1}, {ID1: 1, ID2: 2}}, {{ID1: 0, ID2: 2}}}
+	actual := [][]SatPair{{{ID1: 1, ID2: 2}, {ID1: 0, ID2: 3}}, {{ID1: 0, ID2: 2}}}
+
+	discrepancies := comparePairs(expected, actual)
+	assert.Equal(t, []PairDiscrepancy{{
+		TimeIndex: 0,
+		Expected:  2,
+		Missed:    []SatPair{{ID1: 0, ID2: 1}},
+		Extra:     []SatPair{{ID1: 0, ID2: 3}},
+	}}, discrepancies)
+}
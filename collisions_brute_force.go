@@ -0,0 +1,142 @@
+package main
+
+import (
+	"math"
+	"sync"
+)
+
+// bruteForceCollisionsWithWorkerPool is the exact reference for tier one: it
+// checks every pair of valid samples at every time, O(N^2) per time, with
+// the same per-axis MaxDistance test. Only use it on sub-catalogs.
+func bruteForceCollisionsWithWorkerPool(numTimes int, numSatellites int, ephemeris *Ephemeris, config TierOneConfig) [][]SatPair {
+
+	numWorkers := workerCount(config.Workers)
+	tasks := make(chan int, numTimes)
+	results := make([][]SatPair, numTimes)
+	var wg sync.WaitGroup
+
+	worker := func() {
+		for t := range tasks {
+			results[t] = bruteForcePairsAtTime(t, numSatellites, ephemeris, config.MaxDistance)
+		}
+		wg.Done()
+	}
+
+	for w := 0; w < numWorkers; w++ {
+		wg.Add(1)
+		go worker()
+	}
+
+	for t := 0; t < numTimes; t++ {
+		tasks <- t
+	}
+	close(tasks)
+
+	wg.Wait()
+	return results
+}
+
+func bruteForcePairsAtTime(timeIndex, numSatellites int, ephemeris *Ephemeris, maxDist float64) []SatPair {
+	pairs := []SatPair{}
+	for i := 0; i < numSatellites; i++ {
+		if !ephemeris.isValid(i, timeIndex) {
+			continue
+		}
+		pos1 := ephemeris.Positions[i][timeIndex]
+
+		for j := i + 1; j < numSatellites; j++ {
+			if !ephemeris.isValid(j, timeIndex) {
+				continue
+			}
+			pos2 := ephemeris.Positions[j][timeIndex]
+
+			if math.Abs(pos1.X-pos2.X) <= maxDist && math.Abs(pos1.Y-pos2.Y) <= maxDist && math.Abs(pos1.Z-pos2.Z) <= maxDist {
+				pairs = append(pairs, NewSatPair(i, j))
+			}
+		}
+	}
+	return pairs
+}
+
+// bruteForceSweptCollisionsWithWorkerPool is the exact reference for the
+// swept tier one: the chord test on every pair in every interval, with no
+// spatial index. Intervals are indexed like sweptCollisionsWithWorkerPool.
+func bruteForceSweptCollisionsWithWorkerPool(numTimes int, numSatellites int, ephemeris *Ephemeris, times []JulianDate, config TierOneConfig) [][]SatPair {
+
+	numWorkers := workerCount(config.Workers)
+	tasks := make(chan int, numTimes)
+	results := make([][]SatPair, numTimes)
+	var wg sync.WaitGroup
+
+	worker := func() {
+		for k := range tasks {
+			next := min(k+1, numTimes-1)
+			seconds := differenceInSeconds(times[k], times[next])
+			segments := sweptSegments(k, next, seconds, numSatellites, ephemeris)
+
+			results[k] = []SatPair{}
+			for i := 0; i < numSatellites; i++ {
+				s1, ok := segments[i]
+				if !ok {
+					continue
+				}
+				for j := i + 1; j < numSatellites; j++ {
+					if s2, ok := segments[j]; ok && sweptSegmentsMeet(s1, s2, config.MaxDistance) {
+						results[k] = append(results[k], NewSatPair(i, j))
+					}
+				}
+			}
+		}
+		wg.Done()
+	}
+
+	for w := 0; w < numWorkers; w++ {
+		wg.Add(1)
+		go worker()
+	}
+
+	for k := 0; k < numTimes; k++ {
+		tasks <- k
+	}
+	close(tasks)
+
+	wg.Wait()
+	return results
+}
+
+// PairDiscrepancy lists the pairs tier one got wrong at one time index
+type PairDiscrepancy struct {
+	TimeIndex int
+	Expected  int
+	Missed    []SatPair // Found by brute force only
+	Extra     []SatPair // Found by tier one only
+}
+
+// comparePairs checks tier one against the brute force result, time by
+// time or interval by interval, and returns the indexes where they differ
+func comparePairs(expected, actual [][]SatPair) []PairDiscrepancy {
+	discrepancies := []PairDiscrepancy{}
+	for t := range expected {
+		actualSet := make(map[SatPair]struct{}, len(actual[t]))
+		for _, pair := range actual[t] {
+			actualSet[pair] = struct{}{}
+		}
+
+		discrepancy := PairDiscrepancy{TimeIndex: t, Expected: len(expected[t])}
+		for _, pair := range expected[t] {
+			if _, ok := actualSet[pair]; ok {
+				delete(actualSet, pair)
+			} else {
+				discrepancy.Missed = append(discrepancy.Missed, pair)
+			}
+		}
+		for pair := range actualSet {
+			discrepancy.Extra = append(discrepancy.Extra, pair)
+		}
+
+		if len(discrepancy.Missed) > 0 || len(discrepancy.Extra) > 0 {
+			discrepancies = append(discrepancies, discrepancy)
+		}
+	}
+	return discrepancies
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"math"
+	"sync"
+)
+
+// Margin on the two-body acceleration for J2, drag and third bodies, all well
+// under a percent of it
+const sweptAccelerationMargin = 1.01
+
+// sweptCollisionsWithWorkerPool is the velocity-aware tier one. Between
+// samples k and k+1 each satellite is taken along the straight chord joining
+// its two positions. The true path stays within a*dt^2/8 of the chord, a
+// being the largest gravitational acceleration along it, so a pair can only
+// come within MaxDistance in the interval if the chords come within
+// MaxDistance plus both of those bounds. Unlike checking the samples alone,
+// this cannot skip an encounter whatever the step.
+//
+// results[k] holds the pairs for the interval starting at time k, the last
+// time has no interval and is checked as a point.
+func sweptCollisionsWithWorkerPool(numTimes int, numSatellites int, ephemeris *Ephemeris, times []JulianDate, config TierOneConfig) [][]SatPair {
+
+	numWorkers := workerCount(config.Workers)
+	tasks := make(chan int, numTimes)
+	results := make([][]SatPair, numTimes)
+	var wg sync.WaitGroup
+
+	worker := func() {
+		for k := range tasks {
+			next := min(k+1, numTimes-1)
+			seconds := differenceInSeconds(times[k], times[next])
+			results[k] = sweptPairsInInterval(k, next, seconds, numSatellites, ephemeris, config)
+		}
+		wg.Done()
+	}
+
+	for w := 0; w < numWorkers; w++ {
+		wg.Add(1)
+		go worker()
+	}
+
+	for k := 0; k < numTimes; k++ {
+		tasks <- k
+	}
+	close(tasks)
+
+	wg.Wait()
+	return results
+}
+
+// Chord of one satellite across an interval
+type sweptSegment struct {
+	start, end SatPosition
+	// How far the true path can be from the chord, in km
+	pad float64
+}
+
+// sweptSegments builds the chord of every satellite valid at both ends of
+// the interval
+func sweptSegments(k, next int, seconds float64, numSatellites int, ephemeris *Ephemeris) map[int]sweptSegment {
+	segments := make(map[int]sweptSegment, numSatellites)
+	for i := 0; i < numSatellites; i++ {
+		// Both ends are needed to bound the path
+		if !ephemeris.isValid(i, k) || !ephemeris.isValid(i, next) {
+			continue
+		}
+
+		segment := sweptSegment{start: ephemeris.Positions[i][k], end: ephemeris.Positions[i][next]}
+		radius := segmentDistanceFromOrigin(segment.start, segment.end)
+		acceleration := sweptAccelerationMargin * wgs72Mu / (radius * radius)
+		segment.pad = acceleration * seconds * seconds / 8
+		segments[i] = segment
+	}
+	return segments
+}
+
+// Whether two paths can come within maxDist, from the closest approach of
+// their chords
+func sweptSegmentsMeet(s1, s2 sweptSegment, maxDist float64) bool {
+	relativeStart := subtractPositions(s2.start, s1.start)
+	relativeEnd := subtractPositions(s2.end, s1.end)
+	return segmentDistanceFromOrigin(relativeStart, relativeEnd) <= maxDist+s1.pad+s2.pad
+}
+
+// sweptPairsInInterval indexes every chord by its own bounding box, grown by
+// half the miss distance and its pad, so a pair is a candidate only if both
+// boxes overlap. A fast or eccentric satellite only widens its own box, not
+// the search for the rest of the catalog.
+func sweptPairsInInterval(k, next int, seconds float64, numSatellites int, ephemeris *Ephemeris, config TierOneConfig) []SatPair {
+	segments := sweptSegments(k, next, seconds, numSatellites, ephemeris)
+	boxes := make([]segmentBox, 0, len(segments))
+	for i := 0; i < numSatellites; i++ {
+		segment, ok := segments[i]
+		if !ok {
+			continue
+		}
+		boxes = append(boxes, segment.box(i, config.MaxDistance/2+segment.pad))
+	}
+
+	pairs := []SatPair{}
+	for _, pair := range overlappingBoxes(boxes, config.BoxSize) {
+		if ephemeris.Bands != nil && !ephemeris.Bands[pair.ID1].overlaps(ephemeris.Bands[pair.ID2]) {
+			continue
+		}
+		if sweptSegmentsMeet(segments[pair.ID1], segments[pair.ID2], config.MaxDistance) {
+			pairs = append(pairs, pair)
+		}
+	}
+	return pairs
+}
+
+// Axis aligned box around one satellite's path
+type segmentBox struct {
+	id        int
+	low, high SatPosition
+}
+
+func (s sweptSegment) box(id int, margin float64) segmentBox {
+	return segmentBox{
+		id: id,
+		low: SatPosition{
+			X: math.Min(s.start.X, s.end.X) - margin,
+			Y: math.Min(s.start.Y, s.end.Y) - margin,
+			Z: math.Min(s.start.Z, s.end.Z) - margin,
+		},
+		high: SatPosition{
+			X: math.Max(s.start.X, s.end.X) + margin,
+			Y: math.Max(s.start.Y, s.end.Y) + margin,
+			Z: math.Max(s.start.Z, s.end.Z) + margin,
+		},
+	}
+}
+
+func (b segmentBox) overlaps(other segmentBox) bool {
+	return b.low.X <= other.high.X && other.low.X <= b.high.X &&
+		b.low.Y <= other.high.Y && other.low.Y <= b.high.Y &&
+		b.low.Z <= other.high.Z && other.low.Z <= b.high.Z
+}
+
+// overlappingBoxes hashes each box into every cell it touches and returns the
+// pairs of overlapping boxes. Boxes sharing several cells are only paired in
+// the cell holding the low corner of their overlap, so each pair comes once.
+func overlappingBoxes(boxes []segmentBox, cellSize float64) []SatPair {
+	cells := map[ClusterKey][]segmentBox{}
+	for _, box := range boxes {
+		low := createClusterKey(box.low, cellSize)
+		high := createClusterKey(box.high, cellSize)
+		for x := low.X; x <= high.X; x++ {
+			for y := low.Y; y <= high.Y; y++ {
+				for z := low.Z; z <= high.Z; z++ {
+					key := ClusterKey{X: x, Y: y, Z: z}
+					cells[key] = append(cells[key], box)
+				}
+			}
+		}
+	}
+
+	pairs := []SatPair{}
+	for key, cell := range cells {
+		for i := 0; i < len(cell); i++ {
+			for j := i + 1; j < len(cell); j++ {
+				if !cell[i].overlaps(cell[j]) {
+					continue
+				}
+				corner := SatPosition{
+					X: math.Max(cell[i].low.X, cell[j].low.X),
+					Y: math.Max(cell[i].low.Y, cell[j].low.Y),
+					Z: math.Max(cell[i].low.Z, cell[j].low.Z),
+				}
+				if createClusterKey(corner, cellSize) == key {
+					pairs = append(pairs, NewSatPair(cell[i].id, cell[j].id))
+				}
+			}
+		}
+	}
+	return pairs
+}
+
+func subtractPositions(p1, p2 SatPosition) SatPosition {
+	return SatPosition{X: p1.X - p2.X, Y: p1.Y - p2.Y, Z: p1.Z - p2.Z}
+}
+
+// Closest distance between the origin and the segment from start to end
+func segmentDistanceFromOrigin(start, end SatPosition) float64 {
+	direction := subtractPositions(end, start)
+	lengthSquared := direction.X*direction.X + direction.Y*direction.Y + direction.Z*direction.Z
+
+	s := 0.0
+	if lengthSquared > 0 {
+		s = -(start.X*direction.X + start.Y*direction.Y + start.Z*direction.Z) / lengthSquared
+		s = math.Max(0, math.Min(1, s))
+	}
+	return distanceBetweenPositions(SatPosition{}, SatPosition{
+		X: start.X + s*direction.X,
+		Y: start.Y + s*direction.Y,
+		Z: start.Z + s*direction.Z,
+	})
+}
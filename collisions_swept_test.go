@@ -0,0 +1,212 @@
+package main
+
+import (
+	"io"
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Circular two-body orbit, curved enough between samples to exercise the
+// chord bound
+type circularPropagator struct {
+	id        string
+	reference JulianDate
+	radius    float64
+	// Orthonormal vectors spanning the orbit plane, u is the position at
+	// the reference time
+	u, v  SatPosition
+	phase float64
+}
+
+func (c *circularPropagator) satelliteID() string {
+	return c.id
+}
+
+func (c *circularPropagator) epoch() JulianDate {
+	return c.reference
+}
+
+func (c *circularPropagator) angle(julianDate JulianDate) float64 {
+	rate := math.Sqrt(wgs72Mu / (c.radius * c.radius * c.radius))
+	return c.phase + rate*differenceInSeconds(c.reference, julianDate)
+}
+
+func (c *circularPropagator) propagateAtTime(julianDate JulianDate) (SatPosition, error) {
+	sin, cos := math.Sincos(c.angle(julianDate))
+	return SatPosition{
+		X: c.radius * (cos*c.u.X + sin*c.v.X),
+		Y: c.radius * (cos*c.u.Y + sin*c.v.Y),
+		Z: c.radius * (cos*c.u.Z + sin*c.v.Z),
+	}, nil
+}
+
+func (c *circularPropagator) stateAtTime(julianDate JulianDate) (SatState, error) {
+	position, _ := c.propagateAtTime(julianDate)
+	sin, cos := math.Sincos(c.angle(julianDate))
+	speed := math.Sqrt(wgs72Mu / c.radius)
+	return SatState{
+		JulianTime: julianDate,
+		Position:   position,
+		Velocity: SatVelocity{
+			X: speed * (-sin*c.u.X + cos*c.v.X),
+			Y: speed * (-sin*c.u.Y + cos*c.v.Y),
+			Z: speed * (-sin*c.u.Z + cos*c.v.Z),
+		},
+	}, nil
+}
+
+func stepTimes(start JulianDate, count int, seconds float64) []JulianDate {
+	times := make([]JulianDate, count)
+	for i := range times {
+		times[i] = julianDateAddSeconds(start, float64(i)*seconds)
+	}
+	return times
+}
+
+func flattenPairs(results [][]SatPair) map[SatPair]bool {
+	pairs := map[SatPair]bool{}
+	for _, atTime := range results {
+		for _, pair := range atTime {
+			pairs[pair] = true
+		}
+	}
+	return pairs
+}
+
+func TestSweptFindsEncounterBetweenSamples(t *testing.T) {
+	tca := createJulianDate(2025, 1, 12, 6, 0, 0)
+
+	// Head on at 15 km/s, closest halfway between two 4 minute samples
+	satellites := []Propagator{
+		&linearPropagator{id: "A", reference: tca, position: SatPosition{X: 7000}, velocity: SatPosition{Y: 7.5}},
+		&linearPropagator{id: "B", reference: tca, position: SatPosition{X: 7001}, velocity: SatPosition{Y: -7.5}},
+	}
+	times := stepTimes(julianDateAddSeconds(tca, -120), 3, 240)
+
+	config := defaultConfig()
+	ephemeris := buildSatLocations(satellites, times, config.Ephemeris, io.Discard)
+
+	sampled := tierOneCollisionsWithWorkerPool(len(times), len(satellites), ephemeris, config.TierOne, io.Discard)
+	assert.Empty(t, flattenPairs(sampled))
+
+	swept := sweptCollisionsWithWorkerPool(len(times), len(satellites), ephemeris, times, config.TierOne)
+	assert.Equal(t, []SatPair{NewSatPair(0, 1)}, swept[0])
+
+	events := tierTwoCollisionsWithWorkerPool(swept, nil, times, satellites, config.TierTwo)
+	top := events.getTopPairs(1)
+	assert.InDelta(t, 1.0, top[0].Distance, 0.01)
+}
+
+// Circular orbits of random planes and phases in a thin LEO shell, crowded
+// enough that plenty of pairs pass close
+func randomCircularOrbits(count int, start JulianDate, seed int64) []Propagator {
+	random := rand.New(rand.NewSource(seed))
+	randomUnit := func() SatPosition {
+		p := SatPosition{X: random.NormFloat64(), Y: random.NormFloat64(), Z: random.NormFloat64()}
+		return scalePosition(p, 1/vectorLength(p))
+	}
+
+	satellites := make([]Propagator, count)
+	for i := range satellites {
+		u := randomUnit()
+		w := randomUnit()
+		v := subtractPositions(w, scalePosition(u, dotProduct(u, w)))
+		v = scalePosition(v, 1/vectorLength(v))
+		satellites[i] = &circularPropagator{id: "S", reference: start, radius: 6900 + 20*random.Float64(), u: u, v: v}
+	}
+	return satellites
+}
+
+// Closest separation of each pair over a one second sampling, for the pairs
+// that come within maxDist
+func fineClosePairs(satellites []Propagator, times []JulianDate, maxDist float64) map[SatPair]MinDistancePoint {
+	fine := buildSatLocations(satellites, times, EphemerisConfig{}, io.Discard)
+	closest := map[SatPair]MinDistancePoint{}
+	for k := range times {
+		for i := 0; i < len(satellites); i++ {
+			for j := i + 1; j < len(satellites); j++ {
+				distance := distanceBetweenPositions(fine.Positions[i][k], fine.Positions[j][k])
+				pair := NewSatPair(i, j)
+				if point, ok := closest[pair]; distance <= maxDist && (!ok || distance < point.Distance) {
+					closest[pair] = MinDistancePoint{JulianTime: times[k], Distance: distance}
+				}
+			}
+		}
+	}
+	return closest
+}
+
+// Every pair coming within range at any time of a fine sampling is found
+// from a coarse one
+func TestSweptIsCompleteAtCoarseSteps(t *testing.T) {
+	start := createJulianDate(2025, 1, 12, 0, 0, 0)
+	satellites := randomCircularOrbits(200, start, 3)
+
+	config := defaultConfig()
+	expected := fineClosePairs(satellites, stepTimes(start, 1201, 1), config.TierOne.MaxDistance)
+	assert.NotEmpty(t, expected)
+
+	coarseTimes := stepTimes(start, 6, 240)
+	coarse := buildSatLocations(satellites, coarseTimes, config.Ephemeris, io.Discard)
+	found := flattenPairs(sweptCollisionsWithWorkerPool(len(coarseTimes), len(satellites), coarse, coarseTimes, config.TierOne))
+	for pair := range expected {
+		assert.True(t, found[pair], "missed %v", pair)
+	}
+}
+
+// The chord brute force keeps everything a fine sampling finds, and the
+// indexed search matches it interval by interval
+func TestSweptMatchesBruteForce(t *testing.T) {
+	start := createJulianDate(2025, 1, 12, 0, 0, 0)
+	satellites := randomCircularOrbits(200, start, 3)
+
+	config := defaultConfig()
+	times := stepTimes(start, 6, 240)
+	ephemeris := buildSatLocations(satellites, times, config.Ephemeris, io.Discard)
+	expected := bruteForceSweptCollisionsWithWorkerPool(len(times), len(satellites), ephemeris, times, config.TierOne)
+
+	found := flattenPairs(expected)
+	for pair := range fineClosePairs(satellites, stepTimes(start, 1201, 1), config.TierOne.MaxDistance) {
+		assert.True(t, found[pair], "brute force missed %v", pair)
+	}
+
+	actual := sweptCollisionsWithWorkerPool(len(times), len(satellites), ephemeris, times, config.TierOne)
+	assert.Empty(t, comparePairs(expected, actual))
+}
+
+// Boxes of very different sizes, one spanning most of the shell, are paired
+// exactly when they overlap and each pair comes once
+func TestOverlappingBoxesMatchesBruteForce(t *testing.T) {
+	random := rand.New(rand.NewSource(7))
+	randomBox := func(id int, halfSize float64) segmentBox {
+		centre := SatPosition{X: 14000 * (random.Float64() - 0.5), Y: 14000 * (random.Float64() - 0.5), Z: 14000 * (random.Float64() - 0.5)}
+		half := SatPosition{X: halfSize * random.Float64(), Y: halfSize * random.Float64(), Z: halfSize * random.Float64()}
+		return segmentBox{id: id, low: subtractPositions(centre, half), high: subtractPositions(centre, scalePosition(half, -1))}
+	}
+
+	boxes := []segmentBox{randomBox(0, 6000)}
+	for i := 1; i < 400; i++ {
+		boxes = append(boxes, randomBox(i, 300))
+	}
+
+	expected := map[SatPair]bool{}
+	for i := range boxes {
+		for j := i + 1; j < len(boxes); j++ {
+			if boxes[i].overlaps(boxes[j]) {
+				expected[NewSatPair(boxes[i].id, boxes[j].id)] = true
+			}
+		}
+	}
+	assert.NotEmpty(t, expected)
+
+	pairs := overlappingBoxes(boxes, 1200)
+	found := map[SatPair]bool{}
+	for _, pair := range pairs {
+		assert.False(t, found[pair], "%v paired twice", pair)
+		found[pair] = true
+	}
+	assert.Equal(t, expected, found)
+}
@@ -2,38 +2,86 @@ package main
 
 import (
 	"fmt"
+	"io"
+	"math"
 	"runtime"
 	"sync"
+	"sync/atomic"
 )
 
-func buildSatLocations(satellitesData []Spg4Satellite, times []float64) [][]SatPosition {
-	satLocations := make([][]SatPosition, len(satellitesData))
+// buildSatLocations propagates every satellite at every time, spreading the
+// satellites over a worker pool. Samples that fail are marked invalid and the
+// reason recorded against the satellite. The apsides of the osculating orbit
+// at each sample are kept for the apogee/perigee filter.
+func buildSatLocations(satellites []Propagator, times []JulianDate, config EphemerisConfig, log io.Writer) *Ephemeris {
+	ephemeris := &Ephemeris{
+		Positions: make([][]SatPosition, len(satellites)),
+		Valid:     make([][]bool, len(satellites)),
+		Failures:  make([]PropagationFailures, len(satellites)),
+		Apsides:   make([]AltitudeBand, len(satellites)),
+	}
+
+	numWorkers := workerCount(config.Workers)
+	tasks := make(chan int, len(satellites))
+	var wg sync.WaitGroup
 
-	for i, spg4Sat := range satellitesData {
-		satLocations[i] = make([]SatPosition, len(times))
+	// Progress is logged every tenth of the catalog
+	var completed atomic.Int64
+	progressStep := int64(max(len(satellites)/10, 1))
+
+	// Each worker only writes the rows of the satellites it took, the cgo
+	// backend serialises its own library calls
+	worker := func() {
+		for i := range tasks {
+			ephemeris.Positions[i] = make([]SatPosition, len(times))
+			ephemeris.Valid[i] = make([]bool, len(times))
+			apsides := AltitudeBand{Low: math.Inf(1), High: math.Inf(-1)}
+			for t, julianDate := range times {
+				state, err := satellites[i].stateAtTime(julianDate)
+				if err != nil {
+					ephemeris.Failures[i].add(julianDate, err)
+					continue
+				}
+				ephemeris.Positions[i][t] = state.Position
+				ephemeris.Valid[i][t] = true
 
-		// errorCount := 0
-		for t, julianDate := range times {
-			position, err := spg4Sat.propagateAtTime(julianDate)
-			if err != nil {
-				// fmt.Println("Error running sgp4", err)
-				// errorCount++
-				continue
+				orbit := orbitFromState(state)
+				apsides.Low = math.Min(apsides.Low, orbit.perigee)
+				apsides.High = math.Max(apsides.High, orbit.apogee)
+				if !orbit.valid {
+					apsides = AltitudeBand{Low: math.Inf(-1), High: math.Inf(1)}
+				}
+			}
+			// Without a bound orbit, or any sample, the satellite can be anywhere
+			if apsides.Low > apsides.High {
+				apsides = AltitudeBand{Low: math.Inf(-1), High: math.Inf(1)}
+			}
+			ephemeris.Apsides[i] = apsides
+
+			if n := completed.Add(1); n%progressStep == 0 || n == int64(len(satellites)) {
+				fmt.Fprintln(log, "Propagated", n, "of", len(satellites), "satellites")
 			}
-			satLocations[i][t] = position
 		}
+		wg.Done()
+	}
 
-		// if errorCount > 0 {
-		// 	fmt.Println("Satellite", i, "had", errorCount, "errors")
-		// }
+	for w := 0; w < numWorkers; w++ {
+		wg.Add(1)
+		go worker()
+	}
+
+	for i := range satellites {
+		tasks <- i
 	}
+	close(tasks)
 
-	return satLocations
+	wg.Wait()
+	return ephemeris
 }
 
-func tierOneCollisionsWithWorkerPool(numTimes int, numSatellites int, satLocations [][]SatPosition) [][]SatPair {
+func tierOneCollisionsWithWorkerPool(numTimes int, numSatellites int, ephemeris *Ephemeris, config TierOneConfig, log io.Writer) [][]SatPair {
 
-	numWorkers := runtime.NumCPU() // Number of worker goroutines
+	numWorkers := workerCount(config.Workers) // Number of worker goroutines
 	tasks := make(chan int, numTimes)
 	results := make([][]SatPair, numTimes) // Preallocate result slice
 	var wg sync.WaitGroup
@@ -42,9 +90,9 @@ func tierOneCollisionsWithWorkerPool(numTimes int, numSatellites int, satLocatio
 	worker := func() {
 		for i := range tasks {
 			// time := times[i]
-			timeCluster := NewTimeCluster(i, numSatellites, satLocations)
+			timeCluster := NewTimeCluster(i, numSatellites, ephemeris, config)
 			results[i] = timeCluster.getAtRiskPairs()
-			fmt.Println("At risk pairs", len(results[i]), "for time", i)
+			fmt.Fprintln(log, "At risk pairs", len(results[i]), "for time", i)
 		}
 		wg.Done()
 	}
@@ -65,3 +113,11 @@ func tierOneCollisionsWithWorkerPool(numTimes int, numSatellites int, satLocatio
 	wg.Wait()
 	return results
 }
+
+// Configured worker count, one per CPU when not set
+func workerCount(configured int) int {
+	if configured > 0 {
+		return configured
+	}
+	return runtime.NumCPU()
+}
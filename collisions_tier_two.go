@@ -2,15 +2,21 @@ package main
 
 import (
 	"math"
-	"runtime"
-	"sort"
 	"sync"
 )
 
-func tierTwoCollisionsWithWorkerPool(atRiskPairs [][]SatPair, julianTimes []float64, spg4Satellites []Spg4Satellite) *MinDistancePairs {
+// tierTwoCollisionsWithWorkerPool refines the closest approach of each at
+// risk pair, every local minimum of the range is kept as an event. The
+// search runs config.Window either side of the sample unless windows,
+// indexed like atRiskPairs, narrows it. Samples that fail to propagate are
+// kept in the store's Failures.
+func tierTwoCollisionsWithWorkerPool(atRiskPairs [][]SatPair, windows [][]SearchWindow, julianTimes []JulianDate, satellites []Propagator, config TierTwoConfig) *EventStore {
 
 	// Number of worker goroutines
-	numWorkers := runtime.NumCPU()
+	numWorkers := workerCount(config.Workers)
+	window := config.Window.Seconds()
+	tolerance := config.Tolerance.Seconds()
+	scan := config.ScanStep.Seconds()
 
 	numTasks := 0
 	for _, pairs := range atRiskPairs {
@@ -20,28 +26,41 @@ func tierTwoCollisionsWithWorkerPool(atRiskPairs [][]SatPair, julianTimes []floa
 	tasks := make(chan int, numTasks)
 	var wg sync.WaitGroup
 
-	minDistancePairs := NewMinDistancePairs()
+	catalogIDs := make([]string, len(satellites))
+	for i, satellite := range satellites {
+		catalogIDs[i] = satellite.satelliteID()
+	}
+
+	// Adjacent samples search overlapping windows and find the same closest
+	// approach, the store merges them
+	events := NewEventStore(catalogIDs, config.ScanStep.Seconds())
 
 	// Worker function
 	worker := func() {
 		for i := range tasks {
 			julianTime := julianTimes[i]
 
-			timeLeft := julianDateAddSeconds(julianTime, -10*60)
-			timeRight := julianDateAddSeconds(julianTime, 10*60)
-
-			for _, pair := range atRiskPairs[i] {
-				satOne := spg4Satellites[pair.ID1]
-				satTwo := spg4Satellites[pair.ID2]
+			timeLeft := julianDateAddSeconds(julianTime, -window)
+			timeRight := julianDateAddSeconds(julianTime, window)
 
-				minTime, err := binarySearch(satOne, satTwo, timeLeft, timeRight)
-				if err != nil {
-					// fmt.Println("error propagating sat1", err)
-					continue
+			for j, pair := range atRiskPairs[i] {
+				timeLeft, timeRight := timeLeft, timeRight
+				if windows != nil {
+					timeLeft, timeRight = windows[i][j].Start, windows[i][j].End
 				}
 
-				minDistance, _ := distanceBetweenSatellites(satOne, satTwo, minTime)
-				minDistancePairs.addPair(pair.ID1, pair.ID2, minTime, minDistance)
+				satOne := satellites[pair.ID1]
+				satTwo := satellites[pair.ID2]
+
+				approaches, failures := findCloseApproaches(satOne, satTwo, timeLeft, timeRight, scan, tolerance)
+				events.addFailures(pair.ID1, pair.ID2, failures)
+
+				for _, approach := range approaches {
+					if config.ReportDistance > 0 && approach.Distance > config.ReportDistance {
+						continue
+					}
+					events.addEvent(pair.ID1, pair.ID2, approach)
+				}
 			}
 
 		}
@@ -63,37 +82,10 @@ func tierTwoCollisionsWithWorkerPool(atRiskPairs [][]SatPair, julianTimes []floa
 	// Wait for all workers to finish
 	wg.Wait()
 
-	return minDistancePairs
-}
-
-func binarySearch(sat1, sat2 Spg4Satellite, timeLeft, timeRight float64) (atTime float64, err error) {
-
-	timeMid := (timeLeft + timeRight) / 2.0
-
-	// TODO: this should be seconds not minutes
-	if math.Abs(differenceInSeconds(timeLeft, timeRight)) < 0.1 {
-		return timeMid, nil
-	}
-
-	deltaTime := julianDateAddSeconds(timeMid, -0.05)
-	distanceLeftDelta, err := distanceBetweenSatellites(sat1, sat2, deltaTime)
-	if err != nil {
-		return 0, err
-	}
-
-	distanceMid, err := distanceBetweenSatellites(sat1, sat2, timeMid)
-	if err != nil {
-		return 0, err
-	}
-
-	if distanceLeftDelta < distanceMid {
-		return binarySearch(sat1, sat2, timeLeft, timeMid)
-	} else {
-		return binarySearch(sat1, sat2, timeMid, timeRight)
-	}
+	return events
 }
 
-func distanceBetweenSatellites(sat1, sat2 Spg4Satellite, atTime float64) (float64, error) {
+func distanceBetweenSatellites(sat1, sat2 Propagator, atTime JulianDate) (float64, error) {
 
 	sat1Pos, err := sat1.propagateAtTime(atTime)
 	if err != nil {
@@ -111,63 +103,3 @@ func distanceBetweenSatellites(sat1, sat2 Spg4Satellite, atTime float64) (float6
 func distanceBetweenPositions(sat1Pos, sat2Pos SatPosition) float64 {
 	return math.Sqrt(math.Pow(sat1Pos.X-sat2Pos.X, 2) + math.Pow(sat1Pos.Y-sat2Pos.Y, 2) + math.Pow(sat1Pos.Z-sat2Pos.Z, 2))
 }
-
-type MinDistancePairs struct {
-	pairs sync.Map
-}
-
-type MinDistancePoint struct {
-	JulianTime float64
-	Distance   float64
-}
-
-func NewMinDistancePairs() *MinDistancePairs {
-	return &MinDistancePairs{
-		pairs: sync.Map{},
-	}
-}
-
-func (p *MinDistancePairs) addPair(sat1, sat2 int, julianTime float64, distance float64) {
-	pair := NewSatPair(sat1, sat2)
-	point := MinDistancePoint{
-		JulianTime: julianTime,
-		Distance:   distance,
-	}
-
-	if val, ok := p.pairs.Load(pair); !ok {
-		p.pairs.Store(pair, point)
-	} else if existingPoint, ok := val.(MinDistancePoint); ok && distance < existingPoint.Distance {
-		p.pairs.Store(pair, point)
-	}
-}
-
-type OutPair struct {
-	Sat1ID     int
-	Sat2ID     int
-	JulianTime float64
-	Distance   float64
-}
-
-func (p *MinDistancePairs) getTopPairs(n int) []OutPair {
-
-	results := []OutPair{}
-
-	p.pairs.Range(func(key, value interface{}) bool {
-		pair := key.(SatPair)
-		point := value.(MinDistancePoint)
-		results = append(results, OutPair{
-			Sat1ID:     pair.ID1,
-			Sat2ID:     pair.ID2,
-			JulianTime: point.JulianTime,
-			Distance:   point.Distance,
-		})
-		return true
-	})
-
-	sort.Slice(results, func(i, j int) bool {
-		return results[i].Distance < results[j].Distance
-	})
-
-	return results[:n]
-
-}
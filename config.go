@@ -0,0 +1,334 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config holds every tunable of a screening run. It is read from YAML or
+// JSON (JSON being a subset of YAML), keys not set in the file keep their
+// defaults. Durations are written as Go durations such as "4m" or "100ms".
+type Config struct {
+	Catalog       string              `yaml:"catalog" json:"catalog"`
+	CatalogFilter CatalogFilterConfig `yaml:"catalog_filter" json:"catalog_filter"`
+	Window        WindowConfig        `yaml:"window" json:"window"`
+	Ephemeris     EphemerisConfig     `yaml:"ephemeris" json:"ephemeris"`
+	TierOne       TierOneConfig       `yaml:"tier_one" json:"tier_one"`
+	Prefilter     PrefilterConfig     `yaml:"prefilter" json:"prefilter"`
+	TierTwo       TierTwoConfig       `yaml:"tier_two" json:"tier_two"`
+	Pc            PcConfig            `yaml:"pc" json:"pc"`
+	Output        OutputConfig        `yaml:"output" json:"output"`
+	// leap-seconds.list replacing the built-in table, for leap seconds
+	// announced after this build
+	LeapSeconds string `yaml:"leap_seconds" json:"leap_seconds"`
+}
+
+// Records of the catalog that are screened, matched on the GP metadata.
+// Empty lists keep every value.
+type CatalogFilterConfig struct {
+	// PAYLOAD, ROCKET BODY, DEBRIS or UNKNOWN
+	ObjectTypes  []string `yaml:"object_types" json:"object_types"`
+	CountryCodes []string `yaml:"country_codes" json:"country_codes"`
+	// SMALL, MEDIUM or LARGE
+	RCSSizes []string `yaml:"rcs_sizes" json:"rcs_sizes"`
+	// Objects that re-entered before the window starts are dropped
+	ExcludeDecayed bool `yaml:"exclude_decayed" json:"exclude_decayed"`
+}
+
+type WindowConfig struct {
+	Start    string        `yaml:"start" json:"start"` // ISO-8601 UTC
+	Duration time.Duration `yaml:"duration" json:"duration"`
+	Step     time.Duration `yaml:"step" json:"step"`
+}
+
+type EphemerisConfig struct {
+	// Satellites are propagated in parallel, 0 uses one worker per CPU
+	Workers int `yaml:"workers" json:"workers"`
+}
+
+type TierOneConfig struct {
+	// Check the chords between samples rather than the samples alone, so
+	// no encounter under max_distance_km is skipped whatever the step
+	Swept bool `yaml:"swept" json:"swept"`
+	// Spatial index used to find close pairs at the samples: grid, kdtree
+	// or sweep. Swept chords always go on a grid by their bounding boxes.
+	Index string `yaml:"index" json:"index"`
+	// Edge of the spatial hash cells in km
+	BoxSize float64 `yaml:"box_size_km" json:"box_size_km"`
+	// Largest separation on every axis for a pair to be at risk, in km
+	MaxDistance float64 `yaml:"max_distance_km" json:"max_distance_km"`
+	// 0 uses one worker per CPU
+	Workers int `yaml:"workers" json:"workers"`
+}
+
+// Apogee/perigee, orbit path and time filters run on the tier one pairs
+// before tier two
+type PrefilterConfig struct {
+	Enabled bool `yaml:"enabled" json:"enabled"`
+	// Added to max_distance_km for the perturbations the two-body filters
+	// leave out over the tier two window, in km
+	Pad float64 `yaml:"pad_km" json:"pad_km"`
+}
+
+type TierTwoConfig struct {
+	// The closest approach is searched this long either side of the sample
+	Window time.Duration `yaml:"window" json:"window"`
+	// The range rate is sampled this often to bracket each closest approach
+	ScanStep time.Duration `yaml:"scan_step" json:"scan_step"`
+	// Each closest approach is solved to within this
+	Tolerance time.Duration `yaml:"tolerance" json:"tolerance"`
+	// Pairs whose closest approach is further apart are not reported, in km.
+	// 0 reports every pair.
+	ReportDistance float64 `yaml:"report_distance_km" json:"report_distance_km"`
+	// 0 uses one worker per CPU
+	Workers int `yaml:"workers" json:"workers"`
+}
+
+// Collision probability of each event. TLEs carry no covariance, so the
+// same standard deviations are assumed for every object.
+type PcConfig struct {
+	// foster, chan or alfano
+	Method string `yaml:"method" json:"method"`
+	// Combined hard-body radius of the two objects, km
+	HardBodyRadius float64 `yaml:"hard_body_radius_km" json:"hard_body_radius_km"`
+	// Position standard deviations along each object's RIC axes, km
+	Sigma RICVector `yaml:"sigma" json:"sigma"`
+}
+
+type OutputConfig struct {
+	// Results file, stdout when empty
+	Path string `yaml:"path" json:"path"`
+	// Number of closest pairs reported
+	Count int `yaml:"count" json:"count"`
+	// Events are ranked by distance or pc
+	RankBy string `yaml:"rank_by" json:"rank_by"`
+	// Where the resolved config of the run is written, in addition to the log
+	ResolvedConfig string `yaml:"resolved_config" json:"resolved_config"`
+	// Only events from From up to To are reported, ISO-8601 UTC. Either
+	// may be left empty for no bound.
+	From string `yaml:"from" json:"from"`
+	To   string `yaml:"to" json:"to"`
+}
+
+// Orderings of the reported events
+const (
+	RankByDistance = "distance"
+	RankByPc       = "pc"
+)
+
+func defaultConfig() Config {
+	return Config{
+		Catalog: defaultCatalogPath,
+		CatalogFilter: CatalogFilterConfig{
+			ObjectTypes:    []string{},
+			CountryCodes:   []string{},
+			RCSSizes:       []string{},
+			ExcludeDecayed: true,
+		},
+		Window: WindowConfig{
+			Start:    "2025-01-12T00:00:00Z",
+			Duration: 24 * time.Hour,
+			Step:     4 * time.Minute,
+		},
+		TierOne: TierOneConfig{
+			Swept:       true,
+			Index:       SpatialIndexGrid,
+			BoxSize:     BOX_SIZE,
+			MaxDistance: MAX_DIST,
+		},
+		Prefilter: PrefilterConfig{
+			Enabled: true,
+			Pad:     25,
+		},
+		TierTwo: TierTwoConfig{
+			Window:    10 * time.Minute,
+			ScanStep:  time.Minute,
+			Tolerance: 100 * time.Millisecond,
+		},
+		Pc: PcConfig{
+			Method:         PcFoster,
+			HardBodyRadius: 0.02,
+			Sigma:          RICVector{Radial: 0.2, InTrack: 1, CrossTrack: 0.2},
+		},
+		Output: OutputConfig{
+			Count:  100,
+			RankBy: RankByDistance,
+		},
+	}
+}
+
+// loadConfig reads a config file over config, rejecting unknown keys
+func loadConfig(path string, config *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(config); err != nil && err != io.EOF {
+		return fmt.Errorf("reading config %s: %w", path, err)
+	}
+	return nil
+}
+
+// validate checks the values are usable, so a run fails at startup rather
+// than hours in
+func (c *Config) validate() error {
+	if c.Catalog == "" {
+		return fmt.Errorf("config catalog: must be set")
+	}
+	if _, err := isoToJulianDate(c.Window.Start); err != nil {
+		return fmt.Errorf("config window.start: %w", err)
+	}
+	if c.Window.Step <= 0 {
+		return fmt.Errorf("config window.step: must be positive")
+	}
+	if c.Window.Duration < c.Window.Step {
+		return fmt.Errorf("config window.duration: must be at least one step")
+	}
+	if err := validateSpatialIndex(c.TierOne.Index); err != nil {
+		return fmt.Errorf("config tier_one.index: %w", err)
+	}
+	if c.TierOne.BoxSize <= 0 {
+		return fmt.Errorf("config tier_one.box_size_km: must be positive")
+	}
+	if c.TierOne.MaxDistance <= 0 {
+		return fmt.Errorf("config tier_one.max_distance_km: must be positive")
+	}
+	// Neighbouring cells only reach one box, a larger distance would miss
+	// pairs two cells apart
+	if c.TierOne.BoxSize < c.TierOne.MaxDistance {
+		return fmt.Errorf("config tier_one.box_size_km: must be at least max_distance_km")
+	}
+	if c.Prefilter.Pad < 0 {
+		return fmt.Errorf("config prefilter.pad_km: must not be negative")
+	}
+	if c.TierTwo.Window <= 0 {
+		return fmt.Errorf("config tier_two.window: must be positive")
+	}
+	if c.TierOne.Swept && c.TierTwo.Window < c.Window.Step {
+		return fmt.Errorf("config tier_two.window: must cover a whole step when tier_one.swept is set")
+	}
+	if c.TierTwo.ScanStep <= 0 {
+		return fmt.Errorf("config tier_two.scan_step: must be positive")
+	}
+	// The search spans the window either side of the sample, a minimum is
+	// only bracketed between scan samples inside it
+	if c.TierTwo.ScanStep > c.TierTwo.Window {
+		return fmt.Errorf("config tier_two.scan_step: must be no longer than tier_two.window")
+	}
+	if c.TierTwo.Tolerance <= 0 || c.TierTwo.Tolerance >= c.TierTwo.Window {
+		return fmt.Errorf("config tier_two.tolerance: must be positive and shorter than the window")
+	}
+	if c.TierTwo.ReportDistance < 0 {
+		return fmt.Errorf("config tier_two.report_distance_km: must not be negative")
+	}
+	if c.Ephemeris.Workers < 0 || c.TierOne.Workers < 0 || c.TierTwo.Workers < 0 {
+		return fmt.Errorf("config workers: must not be negative")
+	}
+	if err := validatePcMethod(c.Pc.Method); err != nil {
+		return fmt.Errorf("config pc.method: %w", err)
+	}
+	if c.Pc.HardBodyRadius <= 0 {
+		return fmt.Errorf("config pc.hard_body_radius_km: must be positive")
+	}
+	if c.Pc.Sigma.Radial <= 0 || c.Pc.Sigma.InTrack <= 0 || c.Pc.Sigma.CrossTrack <= 0 {
+		return fmt.Errorf("config pc.sigma: must be positive")
+	}
+	if c.Output.RankBy != RankByDistance && c.Output.RankBy != RankByPc {
+		return fmt.Errorf("config output.rank_by: must be %s or %s", RankByDistance, RankByPc)
+	}
+	if c.Output.Count <= 0 {
+		return fmt.Errorf("config output.count: must be positive")
+	}
+	for _, bound := range []struct{ name, value string }{{"from", c.Output.From}, {"to", c.Output.To}} {
+		if bound.value == "" {
+			continue
+		}
+		if _, err := isoToJulianDate(bound.value); err != nil {
+			return fmt.Errorf("config output.%s: %w", bound.name, err)
+		}
+	}
+	if from, to, ok := c.reportRange(); ok && !from.before(to) {
+		return fmt.Errorf("config output.to: must be after output.from")
+	}
+	return nil
+}
+
+// resolved fills in the values that depend on the machine, so the recorded
+// config reproduces the run
+func (c Config) resolved() Config {
+	if c.Ephemeris.Workers == 0 {
+		c.Ephemeris.Workers = runtime.NumCPU()
+	}
+	if c.TierOne.Workers == 0 {
+		c.TierOne.Workers = runtime.NumCPU()
+	}
+	if c.TierTwo.Workers == 0 {
+		c.TierTwo.Workers = runtime.NumCPU()
+	}
+	start, _ := isoToJulianDate(c.Window.Start)
+	c.Window.Start = formatJulianDate(start)
+	for _, bound := range []*string{&c.Output.From, &c.Output.To} {
+		if *bound != "" {
+			julianDate, _ := isoToJulianDate(*bound)
+			*bound = formatJulianDate(julianDate)
+		}
+	}
+	return c
+}
+
+// reportRange is the time range of the reported events when output.from or
+// output.to is set. A missing bound is taken beyond the tier two search, so
+// it keeps every event on that side.
+func (c Config) reportRange() (JulianDate, JulianDate, bool) {
+	if c.Output.From == "" && c.Output.To == "" {
+		return JulianDate{}, JulianDate{}, false
+	}
+
+	window := c.window()
+	margin := c.TierTwo.Window.Seconds() + window.Step.Seconds()
+	from := julianDateAddSeconds(window.Start, -margin)
+	to := julianDateAddSeconds(window.Start, window.Duration.Seconds()+margin)
+	if c.Output.From != "" {
+		from, _ = isoToJulianDate(c.Output.From)
+	}
+	if c.Output.To != "" {
+		to, _ = isoToJulianDate(c.Output.To)
+	}
+	return from, to, true
+}
+
+// catalogFilter selects the records of the run, decay dates are compared
+// with the window start
+func (c Config) catalogFilter() CatalogFilter {
+	return CatalogFilter{
+		ObjectTypes:    c.CatalogFilter.ObjectTypes,
+		CountryCodes:   c.CatalogFilter.CountryCodes,
+		RCSSizes:       c.CatalogFilter.RCSSizes,
+		ExcludeDecayed: c.CatalogFilter.ExcludeDecayed,
+		DecayedBefore:  julianDateToTime(c.window().Start),
+	}
+}
+
+func (c Config) window() ScreeningWindow {
+	start, _ := isoToJulianDate(c.Window.Start)
+	return ScreeningWindow{Start: start, Duration: c.Window.Duration, Step: c.Window.Step}
+}
+
+// writeConfig records the config as YAML, which loadConfig reads back
+func writeConfig(out io.Writer, config Config) error {
+	encoder := yaml.NewEncoder(out)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(config); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeConfigFile(t *testing.T, name, text string) string {
+	path := filepath.Join(t.TempDir(), name)
+	assert.NoError(t, os.WriteFile(path, []byte(text), 0o644))
+	return path
+}
+
+func TestLoadConfigYAMLAndJSON(t *testing.T) {
+	yamlPath := writeConfigFile(t, "config.yaml", `
+window:
+  start: 2025-02-01T06:00:00Z
+  step: 2m
+tier_one:
+  box_size_km: 800
+  max_distance_km: 50
+tier_two:
+  tolerance: 10ms
+  workers: 3
+catalog_filter:
+  object_types: [PAYLOAD, ROCKET BODY]
+  rcs_sizes: [LARGE]
+`)
+	jsonPath := writeConfigFile(t, "config.json", `{
+		"window": {"start": "2025-02-01T06:00:00Z", "step": "2m"},
+		"tier_one": {"box_size_km": 800, "max_distance_km": 50},
+		"tier_two": {"tolerance": "10ms", "workers": 3},
+		"catalog_filter": {"object_types": ["PAYLOAD", "ROCKET BODY"], "rcs_sizes": ["LARGE"]}
+	}`)
+
+	for _, path := range []string{yamlPath, jsonPath} {
+		config := defaultConfig()
+		assert.NoError(t, loadConfig(path, &config), path)
+		assert.NoError(t, config.validate(), path)
+
+		assert.Equal(t, 2*time.Minute, config.Window.Step)
+		assert.Equal(t, 24*time.Hour, config.Window.Duration)
+		assert.Equal(t, 800.0, config.TierOne.BoxSize)
+		assert.Equal(t, 10*time.Millisecond, config.TierTwo.Tolerance)
+		assert.Equal(t, 10*time.Minute, config.TierTwo.Window)
+		assert.Equal(t, 3, config.TierTwo.Workers)
+
+		filter := config.catalogFilter()
+		assert.Equal(t, []string{ObjectTypePayload, ObjectTypeRocketBody}, filter.ObjectTypes)
+		assert.Equal(t, []string{"LARGE"}, filter.RCSSizes)
+		assert.Empty(t, filter.CountryCodes)
+		assert.True(t, filter.ExcludeDecayed)
+		assert.Equal(t, time.Date(2025, 2, 1, 6, 0, 0, 0, time.UTC), filter.DecayedBefore)
+	}
+}
+
+func TestConfigValidation(t *testing.T) {
+	config := defaultConfig()
+	err := loadConfig(writeConfigFile(t, "typo.yaml", "tier_one:\n  box_size: 800\n"), &config)
+	assert.ErrorContains(t, err, "box_size")
+
+	invalid := []func(c *Config){
+		func(c *Config) { c.Window.Start = "yesterday" },
+		func(c *Config) { c.Window.Step = 0 },
+		func(c *Config) { c.TierOne.MaxDistance = 2 * c.TierOne.BoxSize },
+		func(c *Config) { c.TierTwo.Tolerance = c.TierTwo.Window },
+		func(c *Config) { c.TierTwo.Window = c.Window.Step / 2 },
+		func(c *Config) { c.Prefilter.Pad = -1 },
+		func(c *Config) { c.Pc.Method = "guess" },
+		func(c *Config) { c.Pc.Sigma.InTrack = 0 },
+		func(c *Config) { c.Output.RankBy = "name" },
+		func(c *Config) { c.Output.Count = 0 },
+		func(c *Config) { c.Output.From = "noon" },
+		func(c *Config) { c.Output.From, c.Output.To = "2025-01-12T06:00:00Z", "2025-01-12T05:00:00Z" },
+	}
+	for _, change := range invalid {
+		config := defaultConfig()
+		change(&config)
+		assert.Error(t, config.validate())
+	}
+
+	config = defaultConfig()
+	config.TierOne.BoxSize = config.TierOne.MaxDistance / 2
+	assert.ErrorContains(t, config.validate(), "box_size_km")
+
+	// A scan longer than the window has no samples inside it to bracket
+	config = defaultConfig()
+	config.TierTwo.ScanStep = config.TierTwo.Window + time.Second
+	assert.ErrorContains(t, config.validate(), "scan_step")
+}
+
+func TestResolvedConfigRoundTrip(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", "window:\n  duration: 6h\n")
+
+	fs := flag.NewFlagSet("screen", flag.ContinueOnError)
+	flags := addCommandFlags(fs, defaultConfig())
+	assert.NoError(t, fs.Parse([]string{"-config", path, "-step", "1m", "-count", "5"}))
+
+	config, err := flags.resolve(fs, defaultConfig())
+	assert.NoError(t, err)
+	assert.Equal(t, 6*time.Hour, config.Window.Duration)
+	assert.Equal(t, time.Minute, config.Window.Step)
+	assert.Equal(t, 5, config.Output.Count)
+	assert.Greater(t, config.TierOne.Workers, 0)
+
+	var recorded bytes.Buffer
+	assert.NoError(t, writeConfig(&recorded, config))
+
+	reloaded := Config{}
+	assert.NoError(t, loadConfig(writeConfigFile(t, "resolved.yaml", recorded.String()), &reloaded))
+	assert.Equal(t, config, reloaded)
+}
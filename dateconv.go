@@ -1,23 +1,123 @@
 package main
 
-import "math"
+import (
+	"math"
+	"time"
+)
 
-func createJulianDate(year, mon, day, hr, min, sec int) float64 {
-	return (367.0*float64(year) - math.Floor((7*(float64(year)+math.Floor((float64(mon)+9)/12.0)))*0.25) + math.Floor(275*float64(mon)/9.0) + float64(day) + 1721013.5 + ((float64(sec)/60.0+float64(min))/60.0+float64(hr))/24.0)
+// JulianDate is a Julian date split into the midnight that starts the day and
+// the fraction of the day. A single float64 near 2.46e6 only resolves about
+// 40 us, the fraction on its own resolves well under a nanosecond.
+type JulianDate struct {
+	Day      float64 // Julian date at 0h, always ends in .5
+	Fraction float64 // In [0, 1)
 }
 
-func julianDateAddSeconds(julianDate float64, seconds float64) float64 {
+// newJulianDate normalises any day and fraction, either of which may carry
+// whole days or be negative
+func newJulianDate(day, fraction float64) JulianDate {
+	midnight := math.Floor(day-0.5) + 0.5
+	fraction += day - midnight
+	whole := math.Floor(fraction)
+	return JulianDate{Day: midnight + whole, Fraction: fraction - whole}
+}
+
+func julianDateFromFloat(julianDate float64) JulianDate {
+	return newJulianDate(julianDate, 0)
+}
+
+// float collapses the date to a single number, losing precision
+func (j JulianDate) float() float64 {
+	return j.Day + j.Fraction
+}
+
+func (j JulianDate) before(other JulianDate) bool {
+	return j.Day < other.Day || (j.Day == other.Day && j.Fraction < other.Fraction)
+}
+
+// within reports whether the date is from start up to but not including end
+func (j JulianDate) within(start, end JulianDate) bool {
+	return !j.before(start) && j.before(end)
+}
+
+func (j JulianDate) String() string {
+	return julianDateToISO(j, 6)
+}
+
+func createJulianDate(year, mon, day, hr, min, sec int) JulianDate {
+	midnight := (367.0*float64(year) - math.Floor((7*(float64(year)+math.Floor((float64(mon)+9)/12.0)))*0.25) + math.Floor(275*float64(mon)/9.0) + float64(day) + 1721013.5)
+	return newJulianDate(midnight, ((float64(sec)/60.0+float64(min))/60.0+float64(hr))/24.0)
+}
+
+// julianDateAddSeconds moves a UTC Julian date by SI seconds. A day that ends
+// in a leap second spans 86,401 s, as in timescale.go, so near one the step
+// is taken in TAI.
+func julianDateAddSeconds(julianDate JulianDate, seconds float64) JulianDate {
+	shifted := addDaySeconds(julianDate, seconds)
+	if !leapSecondBetween(julianDate, shifted) {
+		return shifted
+	}
+	return taiToUTC(addDaySeconds(utcToTAI(julianDate), seconds))
+}
+
+// differenceInSeconds is the SI time from the first UTC Julian date to the
+// second, counting any leap seconds in between
+func differenceInSeconds(julianDate1, julianDate2 JulianDate) float64 {
+	if !leapSecondBetween(julianDate1, julianDate2) {
+		return daySecondsBetween(julianDate1, julianDate2)
+	}
+	return daySecondsBetween(utcToTAI(julianDate1), utcToTAI(julianDate2))
+}
+
+// Steps in uniform 86,400 s days, right for TAI and TT and for UTC away from
+// leap seconds
+func addDaySeconds(julianDate JulianDate, seconds float64) JulianDate {
 	deltaDays := seconds / 86400.0
-	return julianDate + deltaDays
+	return newJulianDate(julianDate.Day, julianDate.Fraction+deltaDays)
 }
 
-func differenceInSeconds(julianDate1, julianDate2 float64) float64 {
-	// Calculate the difference in days
-	differenceInDays := julianDate2 - julianDate1
+func daySecondsBetween(julianDate1, julianDate2 JulianDate) float64 {
+	// Calculate the difference in days, whole days and fractions apart so
+	// neither loses precision
+	differenceInDays := (julianDate2.Day - julianDate1.Day) + (julianDate2.Fraction - julianDate1.Fraction)
 	// 86400 seconds in a day
 	return differenceInDays * 86400
 }
 
-func julianDateToUTC50(julianDate float64) float64 {
-	return julianDate - 2433281.5
+// Halfway between two dates
+func julianDateMidpoint(julianDate1, julianDate2 JulianDate) JulianDate {
+	return julianDateAddSeconds(julianDate1, differenceInSeconds(julianDate1, julianDate2)/2)
+}
+
+// uniformUTC restates a UTC Julian date in uniform 86,400 s days, the way
+// SGP4 and TLE epochs count UTC. A time within a leap second falls in the
+// first second of the next day.
+func uniformUTC(julianDate JulianDate) JulianDate {
+	length := utcDayLength(julianDate.Day)
+	if length == 86400 {
+		return julianDate
+	}
+	return newJulianDate(julianDate.Day, julianDate.Fraction*length/86400.0)
+}
+
+func julianDateToUTC50(julianDate JulianDate) float64 {
+	julianDate = uniformUTC(julianDate)
+	return (julianDate.Day - 2433281.5) + julianDate.Fraction
+}
+
+// julianDateToTime converts a UTC Julian date to a time, rounded to the
+// microsecond. A time has no leap seconds, so 23:59:60 becomes the first
+// second of the next day.
+func julianDateToTime(julianDate JulianDate) time.Time {
+	unixEpoch := 2440587.5
+	micros := math.Round((julianDate.Day-unixEpoch)*86400e6 + julianDate.Fraction*utcDayLength(julianDate.Day)*1e6)
+	return time.UnixMicro(int64(micros)).UTC()
+}
+
+// timeToJulianDate converts a UTC time to a Julian date
+func timeToJulianDate(t time.Time) JulianDate {
+	t = t.UTC()
+	midnight := createJulianDate(t.Year(), int(t.Month()), t.Day(), 0, 0, 0)
+	seconds := float64(t.Hour()*3600+t.Minute()*60+t.Second()) + float64(t.Nanosecond())/1e9
+	return julianDateAddSeconds(midnight, seconds)
 }
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJulianDateNormalises(t *testing.T) {
+	jd := newJulianDate(2460688.9, 1.35)
+	assert.Equal(t, 2460689.5, jd.Day)
+	assert.InDelta(t, 0.75, jd.Fraction, 1e-9)
+
+	jd = julianDateAddSeconds(createJulianDate(2025, 1, 12, 0, 0, 0), -1)
+	assert.Equal(t, "2025-01-11T23:59:59.000000Z", jd.String())
+	assert.True(t, jd.before(createJulianDate(2025, 1, 12, 0, 0, 0)))
+}
+
+func TestJulianDateKeepsSubMicrosecondSteps(t *testing.T) {
+	start := createJulianDate(2025, 1, 12, 7, 11, 7)
+
+	// A float64 Julian date would round a 1 us step to a multiple of ~40 us
+	jd := start
+	for i := 0; i < 1000; i++ {
+		jd = julianDateAddSeconds(jd, 1e-6)
+	}
+	assert.InDelta(t, 1e-3, differenceInSeconds(start, jd), 1e-9)
+
+	mid := julianDateMidpoint(start, julianDateAddSeconds(start, 1e-7))
+	assert.InDelta(t, 5e-8, differenceInSeconds(start, mid), 1e-10)
+}
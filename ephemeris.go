@@ -0,0 +1,122 @@
+package main
+
+import (
+	"errors"
+	"strings"
+)
+
+// Ephemeris is the precomputed position of every satellite at every time,
+// indexed [satellite][time]. Valid is false where propagation failed; the
+// position there is meaningless and must not be used.
+type Ephemeris struct {
+	Positions [][]SatPosition
+	Valid     [][]bool
+	Failures  []PropagationFailures
+	// Lowest perigee and highest apogee radius of each satellite's
+	// osculating orbits over the samples, unbounded without a bound orbit
+	Apsides []AltitudeBand
+	// Altitude band of each satellite when the orbit prefilter is on, tier
+	// one then never compares satellites whose bands are apart
+	Bands []AltitudeBand
+}
+
+func (e *Ephemeris) isValid(satellite, timeIndex int) bool {
+	return e.Valid[satellite][timeIndex]
+}
+
+// spatialIndex is the tier one index, split by altitude band when there are
+// bands
+func (e *Ephemeris) spatialIndex(config TierOneConfig) SpatialIndex {
+	index := newSpatialIndex(config)
+	if e.Bands == nil {
+		return index
+	}
+	return bandedIndex{index: index, bands: e.Bands}
+}
+
+type FailureReason int
+
+const (
+	// SGP4 error 6, the orbit went below the surface of the Earth
+	FailureDecayed FailureReason = iota
+	// SGP4 errors 1 and 2, the mean elements cannot be propagated
+	FailureBadElements
+	// SGP4 errors 3 and 4 and anything else the backend reports
+	FailureNumerical
+	numFailureReasons
+)
+
+func (r FailureReason) String() string {
+	switch r {
+	case FailureDecayed:
+		return "decayed"
+	case FailureBadElements:
+		return "bad elements"
+	}
+	return "numerical failure"
+}
+
+// classifyPropagationError maps a propagator error onto a reason. The native
+// backend returns the SGP4 error values, the space-track library only a
+// message.
+func classifyPropagationError(err error) FailureReason {
+	switch {
+	case errors.Is(err, errSgp4Decayed):
+		return FailureDecayed
+	case errors.Is(err, errSgp4MeanMotion), errors.Is(err, errSgp4Eccentricity), errors.Is(err, errSgp4BadElementSet):
+		return FailureBadElements
+	case errors.Is(err, errSgp4PerturbedEcc), errors.Is(err, errSgp4SemiLatus):
+		return FailureNumerical
+	}
+
+	if strings.Contains(strings.ToLower(err.Error()), "decay") {
+		return FailureDecayed
+	}
+	return FailureNumerical
+}
+
+// PropagationFailures records the samples one satellite failed at
+type PropagationFailures struct {
+	Counts [numFailureReasons]int
+	// The earliest failure, usually the time a decaying object came down
+	FirstTime  JulianDate
+	FirstError error
+}
+
+func (f *PropagationFailures) add(julianDate JulianDate, err error) {
+	if f.FirstError == nil {
+		f.FirstTime = julianDate
+		f.FirstError = err
+	}
+	f.Counts[classifyPropagationError(err)]++
+}
+
+// merge adds the failures of other, keeping the earlier first failure
+func (f *PropagationFailures) merge(other PropagationFailures) {
+	if other.FirstError != nil && (f.FirstError == nil || other.FirstTime.before(f.FirstTime)) {
+		f.FirstTime = other.FirstTime
+		f.FirstError = other.FirstError
+	}
+	for reason, count := range other.Counts {
+		f.Counts[reason] += count
+	}
+}
+
+func (f PropagationFailures) total() int {
+	total := 0
+	for _, count := range f.Counts {
+		total += count
+	}
+	return total
+}
+
+// Main reason, the one with the most samples
+func (f PropagationFailures) reason() FailureReason {
+	reason := FailureReason(0)
+	for r := FailureReason(1); r < numFailureReasons; r++ {
+		if f.Counts[r] > f.Counts[reason] {
+			reason = r
+		}
+	}
+	return reason
+}
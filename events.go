@@ -0,0 +1,200 @@
+package main
+
+import (
+	"math"
+	"sort"
+	"sync"
+)
+
+// Closest approach of a pair, one conjunction event. The first satellite of
+// the pair is the primary.
+type MinDistancePoint struct {
+	JulianTime JulianDate
+	Distance   float64
+	Encounter
+	// States at closest approach
+	Primary, Secondary SatState
+}
+
+// EventStore keeps every distinct closest approach of each pair. Refinements
+// of a pair less than mergeSeconds apart are the same event found from
+// neighbouring samples and are merged, keeping the closer.
+type EventStore struct {
+	mu sync.Mutex
+	// Events of each pair in time order
+	pairs map[SatPair][]MinDistancePoint
+	// Catalog number of each satellite, indexed like the satellites slice
+	catalogIDs   []string
+	mergeSeconds float64
+	// Samples of each pair's search that failed to propagate, events near
+	// them may be missing
+	Failures map[SatPair]PropagationFailures
+}
+
+func NewEventStore(catalogIDs []string, mergeSeconds float64) *EventStore {
+	return &EventStore{
+		pairs:        map[SatPair][]MinDistancePoint{},
+		Failures:     map[SatPair]PropagationFailures{},
+		catalogIDs:   catalogIDs,
+		mergeSeconds: mergeSeconds,
+	}
+}
+
+func (s *EventStore) addEvent(sat1, sat2 int, point MinDistancePoint) {
+	pair := NewSatPair(sat1, sat2)
+	if pair.ID1 != sat1 {
+		point = point.swapped()
+	}
+	julianTime := point.JulianTime
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	events := s.pairs[pair]
+	i := sort.Search(len(events), func(i int) bool {
+		return !events[i].JulianTime.before(julianTime)
+	})
+	// Only the neighbours in time can be the same event
+	for _, j := range []int{i - 1, i} {
+		if j < 0 || j >= len(events) || math.Abs(differenceInSeconds(events[j].JulianTime, julianTime)) > s.mergeSeconds {
+			continue
+		}
+		if point.Distance < events[j].Distance {
+			events[j] = point
+		}
+		return
+	}
+
+	events = append(events, MinDistancePoint{})
+	copy(events[i+1:], events[i:])
+	events[i] = point
+	s.pairs[pair] = events
+}
+
+func (s *EventStore) addFailures(sat1, sat2 int, failures PropagationFailures) {
+	if failures.total() == 0 {
+		return
+	}
+	pair := NewSatPair(sat1, sat2)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	merged := s.Failures[pair]
+	merged.merge(failures)
+	s.Failures[pair] = merged
+}
+
+// The same event seen from the secondary, the geometry is recomputed in its
+// frame. Pc does not depend on the order.
+func (p MinDistancePoint) swapped() MinDistancePoint {
+	p.Primary, p.Secondary = p.Secondary, p.Primary
+	if p.Primary != (SatState{}) {
+		pc := p.Pc
+		p.Encounter = encounterGeometry(p.Primary, p.Secondary)
+		p.Pc = pc
+	}
+	return p
+}
+
+// Events of one pair in time order
+func (s *EventStore) pairEvents(sat1, sat2 int) []MinDistancePoint {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]MinDistancePoint{}, s.pairs[NewSatPair(sat1, sat2)]...)
+}
+
+// Every event, in no particular order
+func (s *EventStore) events() []OutPair {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	results := []OutPair{}
+	for pair, events := range s.pairs {
+		for _, point := range events {
+			results = append(results, OutPair{
+				Sat1ID:        pair.ID1,
+				Sat2ID:        pair.ID2,
+				Sat1CatalogID: s.catalogIDs[pair.ID1],
+				Sat2CatalogID: s.catalogIDs[pair.ID2],
+				JulianTime:    point.JulianTime,
+				Distance:      point.Distance,
+				Encounter:     point.Encounter,
+			})
+		}
+	}
+	return results
+}
+
+// The n closest events, a pair meeting several times can appear more than
+// once
+func (s *EventStore) getTopPairs(n int) []OutPair {
+	return closestEvents(s.events(), n)
+}
+
+// The n events most likely to collide, events without a probability last
+func (s *EventStore) getTopPairsByPc(n int) []OutPair {
+	return likeliestEvents(s.events(), n)
+}
+
+func closestEvents(results []OutPair, n int) []OutPair {
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Distance < results[j].Distance
+	})
+
+	if n > len(results) {
+		n = len(results)
+	}
+	return results[:n]
+}
+
+func likeliestEvents(results []OutPair, n int) []OutPair {
+	sort.SliceStable(results, func(i, j int) bool {
+		return pcRanksBefore(results[i].Pc, results[j].Pc)
+	})
+
+	if n > len(results) {
+		n = len(results)
+	}
+	return results[:n]
+}
+
+// Events from start up to but not including end, in time order
+func (s *EventStore) eventsBetween(start, end JulianDate) []OutPair {
+	results := []OutPair{}
+	for _, event := range s.events() {
+		if event.JulianTime.within(start, end) {
+			results = append(results, event)
+		}
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].JulianTime.before(results[j].JulianTime)
+	})
+	return results
+}
+
+type OutPair struct {
+	Sat1ID         int
+	Sat2ID         int
+	Sat1CatalogID  string
+	Sat2CatalogID  string
+	Sat1ObjectType string
+	Sat2ObjectType string
+	JulianTime     JulianDate
+	Distance       float64
+	Encounter
+}
+
+// labelPairs sets the object type of each side from the catalog records the
+// satellite IDs index into
+func labelPairs(pairs []OutPair, satellitesData []SatelliteApiData) {
+	for i := range pairs {
+		pairs[i].Sat1ObjectType = satellitesData[pairs[i].Sat1ID].ObjectType
+		pairs[i].Sat2ObjectType = satellitesData[pairs[i].Sat2ID].ObjectType
+	}
+}
+
+// Pair kind such as "PAY-DEB" for reports
+func (p OutPair) label() string {
+	return objectTypeLabel(p.Sat1ObjectType) + "-" + objectTypeLabel(p.Sat2ObjectType)
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"io"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventStoreMergesAndQueries(t *testing.T) {
+	at := createJulianDate(2025, 1, 12, 0, 0, 0)
+	store := NewEventStore([]string{"A", "B", "C"}, 60)
+
+	// The same approach refined from two samples, then two more meetings
+	store.addEvent(0, 1, MinDistancePoint{JulianTime: julianDateAddSeconds(at, 0.05), Distance: 2.1})
+	store.addEvent(1, 0, MinDistancePoint{JulianTime: at, Distance: 2.0})
+	store.addEvent(0, 1, MinDistancePoint{JulianTime: julianDateAddSeconds(at, 3*3600), Distance: 5})
+	store.addEvent(0, 1, MinDistancePoint{JulianTime: julianDateAddSeconds(at, 3600), Distance: 1})
+	store.addEvent(1, 2, MinDistancePoint{JulianTime: julianDateAddSeconds(at, 1800), Distance: 3})
+
+	events := store.pairEvents(0, 1)
+	assert.Len(t, events, 3)
+	assert.Equal(t, at, events[0].JulianTime)
+	assert.Equal(t, 2.0, events[0].Distance)
+	assert.Equal(t, []float64{2, 1, 5}, []float64{events[0].Distance, events[1].Distance, events[2].Distance})
+
+	top := store.getTopPairs(2)
+	assert.Equal(t, []float64{1, 2}, []float64{top[0].Distance, top[1].Distance})
+
+	between := store.eventsBetween(at, julianDateAddSeconds(at, 3600))
+	assert.Len(t, between, 2)
+	assert.Equal(t, "A", between[0].Sat1CatalogID)
+	assert.Equal(t, "C", between[1].Sat2CatalogID)
+}
+
+func TestEventStoreKeepsPrimaryFrame(t *testing.T) {
+	at := createJulianDate(2025, 1, 12, 0, 0, 0)
+	sat1 := &linearPropagator{id: "A", reference: at, position: SatPosition{X: 7000}, velocity: SatPosition{Y: 7}}
+	sat2 := &linearPropagator{id: "B", reference: at, position: SatPosition{X: 7001, Z: 0.5}, velocity: SatPosition{Y: 6, Z: 1}}
+	expected, _ := closeApproachAt(sat1, sat2, at)
+	reversed, _ := closeApproachAt(sat2, sat1, at)
+	reversed.Pc = 1e-4
+
+	// Added from the secondary, stored from satellite 0
+	store := NewEventStore([]string{"A", "B"}, 60)
+	store.addEvent(1, 0, reversed)
+	event := store.pairEvents(0, 1)[0]
+	assert.Equal(t, expected.Primary, event.Primary)
+	assert.InDelta(t, expected.Miss.Radial, event.Miss.Radial, 1e-9)
+	assert.InDelta(t, expected.Miss.InTrack, event.Miss.InTrack, 1e-9)
+	assert.InDelta(t, expected.Miss.CrossTrack, event.Miss.CrossTrack, 1e-9)
+	assert.InDelta(t, expected.ApproachAngle, event.ApproachAngle, 1e-9)
+	assert.Equal(t, 1e-4, event.Pc)
+}
+
+func TestTierTwoKeepsEveryMeeting(t *testing.T) {
+	at := createJulianDate(2025, 1, 12, 0, 0, 0)
+	sin, cos := math.Sincos(math.Pi / 3)
+	satellites := []Propagator{
+		&circularPropagator{id: "A", reference: at, radius: 7000, u: SatPosition{X: 1}, v: SatPosition{Y: 1}},
+		&circularPropagator{id: "B", reference: at, radius: 7000, u: SatPosition{X: 1}, v: SatPosition{Y: cos, Z: sin}, phase: 0.001},
+	}
+
+	// Meets at both nodes every orbit, each seen from several samples
+	times := stepTimes(at, 60, 240)
+	config := defaultConfig()
+	ephemeris := buildSatLocations(satellites, times, config.Ephemeris, io.Discard)
+	atRiskPairs := sweptCollisionsWithWorkerPool(len(times), len(satellites), ephemeris, times, config.TierOne)
+	events := tierTwoCollisionsWithWorkerPool(atRiskPairs, nil, times, satellites, config.TierTwo)
+
+	halfPeriod := math.Pi * math.Sqrt(7000*7000*7000/wgs72Mu)
+	meetings := events.pairEvents(0, 1)
+	assert.Len(t, meetings, int(differenceInSeconds(times[0], times[len(times)-1])/halfPeriod)+1)
+	for i := 1; i < len(meetings); i++ {
+		assert.InDelta(t, halfPeriod, differenceInSeconds(meetings[i-1].JulianTime, meetings[i].JulianTime), 1)
+	}
+}
+
+func TestAttachPcRanksFailuresLast(t *testing.T) {
+	at := createJulianDate(2025, 1, 12, 0, 0, 0)
+	sat1 := &linearPropagator{id: "A", reference: at, position: SatPosition{X: 7000}, velocity: SatPosition{Y: 7}}
+	sat2 := &linearPropagator{id: "B", reference: at, position: SatPosition{X: 7000.1}, velocity: SatPosition{Z: 7}}
+	approach, _ := closeApproachAt(sat1, sat2, at)
+
+	// An event without states has no RIC axes for the covariance
+	store := NewEventStore([]string{"A", "B", "C"}, 60)
+	store.addEvent(0, 2, MinDistancePoint{JulianTime: at, Distance: 0.05})
+	store.addEvent(0, 1, approach)
+
+	failed, err := store.attachPc(defaultConfig().Pc, 2)
+	assert.Equal(t, 1, failed)
+	assert.Error(t, err)
+
+	top := store.getTopPairsByPc(2)
+	assert.Equal(t, "B", top[0].Sat2CatalogID)
+	assert.Greater(t, top[0].Pc, 0.0)
+	assert.True(t, math.IsNaN(top[1].Pc))
+}
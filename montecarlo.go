@@ -0,0 +1,282 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sync"
+)
+
+// Propagators that can be rebuilt from perturbed mean elements, which Monte
+// Carlo needs to propagate each sample
+type DispersiblePropagator interface {
+	dispersed(dispersion ElementDispersion) (Propagator, error)
+}
+
+// Changes to a satellite's mean elements. The eccentricity vector, e along
+// perigee, is perturbed rather than e and the argument of perigee, which are
+// ill defined on near circular orbits. Angles in radians.
+type ElementDispersion struct {
+	EccentricityX float64 // along the line of nodes
+	EccentricityY float64
+	Inclination   float64
+	RAAN          float64
+	// Applied to the mean argument of latitude
+	MeanAnomaly float64
+}
+
+// apply perturbs mean elements, keeping the mean argument of latitude apart
+// from the MeanAnomaly change when perigee moves
+func (d ElementDispersion) apply(elements sgp4Elements) sgp4Elements {
+	x := elements.Eccentricity*math.Cos(elements.ArgPerigee) + d.EccentricityX
+	y := elements.Eccentricity*math.Sin(elements.ArgPerigee) + d.EccentricityY
+	argPerigee := math.Atan2(y, x)
+
+	elements.MeanAnomaly += d.MeanAnomaly + elements.ArgPerigee - argPerigee
+	elements.ArgPerigee = argPerigee
+	elements.Eccentricity = math.Hypot(x, y)
+	elements.Inclination += d.Inclination
+	elements.RAAN += d.RAAN
+	return elements
+}
+
+func dispersionFromVector(v [5]float64) ElementDispersion {
+	return ElementDispersion{EccentricityX: v[0], EccentricityY: v[1], Inclination: v[2], RAAN: v[3], MeanAnomaly: v[4]}
+}
+
+// Element changes that move a satellite along its RIC axes at one time
+type dispersionMap struct {
+	// Minimum norm inverse of the 3x5 Jacobian of the RIC position at that
+	// time with respect to the element changes
+	inverse [5][3]float64
+}
+
+// Element change used for the finite differences, radians
+const dispersionStep = 1e-6
+
+// newDispersionMap differentiates the satellite's position at the state's
+// time with respect to each element change, through the propagator. Element
+// changes also alter the mean motion and J2 rates, so the offsets grow from
+// the epoch in ways a closed form for a Keplerian orbit misses.
+func newDispersionMap(satellite DispersiblePropagator, nominal SatState) (dispersionMap, error) {
+	radial, inTrack, crossTrack := ricAxes(nominal)
+
+	var jacobian [3][5]float64
+	for k := 0; k < 5; k++ {
+		var change [5]float64
+		change[k] = dispersionStep
+		perturbed, err := satellite.dispersed(dispersionFromVector(change))
+		if err != nil {
+			return dispersionMap{}, err
+		}
+		position, err := perturbed.propagateAtTime(nominal.JulianTime)
+		if err != nil {
+			return dispersionMap{}, err
+		}
+		offset := subtractPositions(position, nominal.Position)
+		jacobian[0][k] = dotProduct(offset, radial) / dispersionStep
+		jacobian[1][k] = dotProduct(offset, inTrack) / dispersionStep
+		jacobian[2][k] = dotProduct(offset, crossTrack) / dispersionStep
+	}
+
+	// J^T (J J^T)^-1
+	var product [3][3]float64
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			for k := 0; k < 5; k++ {
+				product[i][j] += jacobian[i][k] * jacobian[j][k]
+			}
+		}
+	}
+	productInverse, ok := invert3(product)
+	if !ok {
+		return dispersionMap{}, fmt.Errorf("element changes cannot move the satellite along every RIC axis")
+	}
+
+	m := dispersionMap{}
+	for k := 0; k < 5; k++ {
+		for j := 0; j < 3; j++ {
+			for i := 0; i < 3; i++ {
+				m.inverse[k][j] += jacobian[i][k] * productInverse[i][j]
+			}
+		}
+	}
+	return m, nil
+}
+
+// sample draws element changes that move the satellite by Gaussian offsets
+// of sigma along its RIC axes, to first order
+func (m dispersionMap) sample(random *rand.Rand, sigma RICVector) ElementDispersion {
+	offset := [3]float64{
+		sigma.Radial * random.NormFloat64(),
+		sigma.InTrack * random.NormFloat64(),
+		sigma.CrossTrack * random.NormFloat64(),
+	}
+	var change [5]float64
+	for k := 0; k < 5; k++ {
+		for j := 0; j < 3; j++ {
+			change[k] += m.inverse[k][j] * offset[j]
+		}
+	}
+	return dispersionFromVector(change)
+}
+
+func invert3(m [3][3]float64) ([3][3]float64, bool) {
+	var inverse [3][3]float64
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			// Cofactor of (j, i)
+			a, b := (j+1)%3, (j+2)%3
+			c, d := (i+1)%3, (i+2)%3
+			inverse[i][j] = m[a][c]*m[b][d] - m[a][d]*m[b][c]
+		}
+	}
+	determinant := m[0][0]*inverse[0][0] + m[0][1]*inverse[1][0] + m[0][2]*inverse[2][0]
+	if determinant == 0 {
+		return inverse, false
+	}
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			inverse[i][j] /= determinant
+		}
+	}
+	return inverse, true
+}
+
+// Monte Carlo estimate of the collision probability of one event
+type MonteCarloResult struct {
+	Samples int
+	Hits    int
+	// Samples whose elements could not be propagated, left out of Samples
+	Failed int
+	Pc     float64
+	// 95% Wilson score interval
+	Low, High float64
+}
+
+// Samples drawn from one random source, fixed so the result depends on the
+// seed but not the number of workers
+const monteCarloBatch = 100
+
+// monteCarloPcWithWorkerPool samples element dispersions of both satellites
+// around the event, propagates every sample over windowSeconds either side
+// of the closest approach and counts the samples that come within the hard
+// body radius. Unlike the 2D methods this holds for slow, long encounters.
+func monteCarloPcWithWorkerPool(sat1, sat2 Propagator, event MinDistancePoint, samples int, seed int64, windowSeconds float64, tierTwo TierTwoConfig, config PcConfig) (MonteCarloResult, error) {
+	dispersible1, ok1 := sat1.(DispersiblePropagator)
+	dispersible2, ok2 := sat2.(DispersiblePropagator)
+	if !ok1 || !ok2 {
+		return MonteCarloResult{}, fmt.Errorf("monte carlo needs propagators that can be rebuilt from perturbed elements")
+	}
+	map1, err := newDispersionMap(dispersible1, event.Primary)
+	if err != nil {
+		return MonteCarloResult{}, err
+	}
+	map2, err := newDispersionMap(dispersible2, event.Secondary)
+	if err != nil {
+		return MonteCarloResult{}, err
+	}
+
+	timeLeft := julianDateAddSeconds(event.JulianTime, -windowSeconds)
+	timeRight := julianDateAddSeconds(event.JulianTime, windowSeconds)
+	scan := tierTwo.ScanStep.Seconds()
+	// The range found is off by up to the relative speed times the time
+	// error, which must stay well inside the hard body
+	tolerance := tierTwo.Tolerance.Seconds()
+	if event.RelativeSpeed > 0 {
+		tolerance = math.Min(tolerance, 0.01*config.HardBodyRadius/event.RelativeSpeed)
+	}
+
+	numBatches := (samples + monteCarloBatch - 1) / monteCarloBatch
+	tasks := make(chan int, numBatches)
+	var mu sync.Mutex
+	result := MonteCarloResult{}
+	var wg sync.WaitGroup
+
+	// A sample hits if the range is within the radius at a closest approach
+	// in the window or at either end of it
+	hit := func(sample1, sample2 Propagator) (bool, error) {
+		approaches, failures := findCloseApproaches(sample1, sample2, timeLeft, timeRight, scan, tolerance)
+		if failures.total() > 0 {
+			return false, failures.FirstError
+		}
+		for _, approach := range approaches {
+			if approach.Distance <= config.HardBodyRadius {
+				return true, nil
+			}
+		}
+		for _, edge := range []JulianDate{timeLeft, timeRight} {
+			distance, err := distanceBetweenSatellites(sample1, sample2, edge)
+			if err != nil {
+				return false, err
+			}
+			if distance <= config.HardBodyRadius {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+
+	worker := func() {
+		for batch := range tasks {
+			random := rand.New(rand.NewSource(seed + int64(batch)))
+			count := min(monteCarloBatch, samples-batch*monteCarloBatch)
+			batchResult := MonteCarloResult{}
+
+			for i := 0; i < count; i++ {
+				sample1, err1 := dispersible1.dispersed(map1.sample(random, config.Sigma))
+				sample2, err2 := dispersible2.dispersed(map2.sample(random, config.Sigma))
+				if err1 != nil || err2 != nil {
+					batchResult.Failed++
+					continue
+				}
+
+				isHit, err := hit(sample1, sample2)
+				if err != nil {
+					batchResult.Failed++
+					continue
+				}
+				batchResult.Samples++
+				if isHit {
+					batchResult.Hits++
+				}
+			}
+
+			mu.Lock()
+			result.Samples += batchResult.Samples
+			result.Hits += batchResult.Hits
+			result.Failed += batchResult.Failed
+			mu.Unlock()
+		}
+		wg.Done()
+	}
+
+	for w := 0; w < workerCount(tierTwo.Workers); w++ {
+		wg.Add(1)
+		go worker()
+	}
+
+	for batch := 0; batch < numBatches; batch++ {
+		tasks <- batch
+	}
+	close(tasks)
+
+	wg.Wait()
+
+	if result.Samples == 0 {
+		return result, fmt.Errorf("no samples could be propagated, %d failed", result.Failed)
+	}
+	result.Pc = float64(result.Hits) / float64(result.Samples)
+	result.Low, result.High = wilsonInterval(result.Hits, result.Samples, 1.959964)
+	return result, nil
+}
+
+// Wilson score interval of a binomial proportion, which stays inside [0, 1]
+// and is useful with no hits at all
+func wilsonInterval(hits, samples int, z float64) (float64, float64) {
+	n := float64(samples)
+	p := float64(hits) / n
+	centre := (p + z*z/(2*n)) / (1 + z*z/n)
+	halfWidth := z / (1 + z*z/n) * math.Sqrt(p*(1-p)/n+z*z/(4*n*n))
+	return math.Max(0, centre-halfWidth), math.Min(1, centre+halfWidth)
+}
@@ -0,0 +1,81 @@
+//go:build !spacetrack
+
+package main
+
+import (
+	"bytes"
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Only the native propagator can be dispersed, so these tests are left out of
+// the spacetrack build.
+
+func TestDispersionMapReproducesSigma(t *testing.T) {
+	state, _ := satTwo.stateAtTime(CloseCollisionTime)
+	dispersionMap, err := newDispersionMap(satTwo, state)
+	assert.NoError(t, err)
+
+	sigma := RICVector{Radial: 0.1, InTrack: 0.5, CrossTrack: 0.2}
+	radial, inTrack, crossTrack := ricAxes(state)
+	random := rand.New(rand.NewSource(1))
+	samples := 5000
+	var variance [3]float64
+	for i := 0; i < samples; i++ {
+		sample, err := satTwo.dispersed(dispersionMap.sample(random, sigma))
+		assert.NoError(t, err)
+		position, _ := sample.propagateAtTime(CloseCollisionTime)
+		offset := subtractPositions(position, state.Position)
+		for axis, direction := range []SatPosition{radial, inTrack, crossTrack} {
+			variance[axis] += dotProduct(offset, direction) * dotProduct(offset, direction) / float64(samples)
+		}
+	}
+
+	// Within a few standard errors of the sample deviation
+	for axis, expected := range []float64{sigma.Radial, sigma.InTrack, sigma.CrossTrack} {
+		assert.InEpsilon(t, expected, math.Sqrt(variance[axis]), 0.05)
+	}
+}
+
+func TestMonteCarloMatchesFosterForFastEncounter(t *testing.T) {
+	event, _ := closeApproachAt(satTwo, satThree, CloseCollisionTime)
+	config := defaultConfig()
+	config.Pc.HardBodyRadius = 0.1
+	config.Pc.Sigma = RICVector{Radial: 0.1, InTrack: 0.1, CrossTrack: 0.1}
+	config.TierTwo.Workers = 4
+
+	pc, err := eventPc(event, config.Pc)
+	assert.NoError(t, err)
+
+	result, err := monteCarloPcWithWorkerPool(satTwo, satThree, event, 20000, 1, 120, config.TierTwo, config.Pc)
+	assert.NoError(t, err)
+	assert.Equal(t, 20000, result.Samples)
+	assert.Zero(t, result.Failed)
+	// Straight line relative motion holds at 1 km/s, so the 2D result
+	// should be inside the interval
+	assert.Less(t, result.Low, pc)
+	assert.Greater(t, result.High, pc)
+
+	// The seed fixes the result whatever the number of workers
+	config.TierTwo.Workers = 1
+	again, err := monteCarloPcWithWorkerPool(satTwo, satThree, event, 20000, 1, 120, config.TierTwo, config.Pc)
+	assert.NoError(t, err)
+	assert.Equal(t, result, again)
+}
+
+func TestMonteCarloCommand(t *testing.T) {
+	var out, log bytes.Buffer
+	err := run([]string{"montecarlo", "-catalog", writeTestCatalog(t), "-sat1", "56700", "-sat2", "58247",
+		"-start", "2025-01-12T18:00:00Z", "-duration", "2h", "-samples", "200"}, &out, &log)
+	assert.NoError(t, err)
+	assert.Contains(t, log.String(), "Resolved config:")
+
+	fields := strings.Fields(out.String())
+	assert.Len(t, fields, 9)
+	assert.True(t, strings.HasPrefix(fields[0], "2025-01-12T19:11:"), fields[0])
+	assert.Equal(t, "200", fields[7])
+}
@@ -0,0 +1,16 @@
+//go:build spacetrack
+
+package main
+
+import (
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMonteCarloCommandRefusedWithoutDispersion(t *testing.T) {
+	// Fails before the catalog is read
+	err := run([]string{"montecarlo", "-catalog", "missing.json", "-sat1", "56700", "-sat2", "58247"}, io.Discard, io.Discard)
+	assert.ErrorContains(t, err, "without -tags spacetrack")
+}
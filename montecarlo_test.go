@@ -0,0 +1,30 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMonteCarloNeedsDispersiblePropagators(t *testing.T) {
+	at := createJulianDate(2025, 1, 12, 0, 0, 0)
+	sat1 := &linearPropagator{id: "A", reference: at, position: SatPosition{X: 7000}, velocity: SatPosition{Y: 7}}
+	sat2 := &linearPropagator{id: "B", reference: at, position: SatPosition{X: 7001}, velocity: SatPosition{Y: -7}}
+	event, _ := closeApproachAt(sat1, sat2, at)
+
+	config := defaultConfig()
+	config.TierTwo.Tolerance = 10 * time.Millisecond
+	_, err := monteCarloPcWithWorkerPool(sat1, sat2, event, 10, 1, 60, config.TierTwo, config.Pc)
+	assert.Error(t, err)
+}
+
+func TestWilsonInterval(t *testing.T) {
+	low, high := wilsonInterval(0, 1000, 1.959964)
+	assert.InDelta(t, 0, low, 1e-12)
+	assert.InDelta(t, 3.84/1003.84, high, 1e-4)
+
+	low, high = wilsonInterval(500, 1000, 1.959964)
+	assert.InDelta(t, 0.469, low, 1e-3)
+	assert.InDelta(t, 0.531, high, 1e-3)
+}
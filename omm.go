@@ -0,0 +1,381 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OMM is a CCSDS Orbit Mean-Elements Message (CCSDS 502.0-B) carrying SGP4
+// mean elements, as published by Space-Track and CelesTrak. Angles are in
+// degrees and mean motion in revolutions per day.
+type OMM struct {
+	ObjectName        string
+	ObjectID          string
+	CenterName        string
+	RefFrame          string
+	TimeSystem        string
+	MeanElementTheory string
+
+	Epoch         string
+	MeanMotion    float64
+	Eccentricity  float64
+	Inclination   float64
+	RAAN          float64
+	ArgPericenter float64
+	MeanAnomaly   float64
+
+	EphemerisType      int
+	ClassificationType string
+	NoradCatID         CatalogNumber
+	ElementSetNo       int
+	RevAtEpoch         int
+	Bstar              float64
+	MeanMotionDot      float64
+	MeanMotionDDot     float64
+
+	// Every keyword as read, including ones without a field above, so other
+	// metadata in Space-Track GP records is not lost
+	Fields map[string]string
+
+	// First problem found while reading the keywords, reported by Validate so
+	// one bad record does not stop a whole file from loading
+	err error
+}
+
+var ommRequiredKeywords = []string{
+	"EPOCH", "MEAN_MOTION", "ECCENTRICITY", "INCLINATION", "RA_OF_ASC_NODE", "ARG_OF_PERICENTER", "MEAN_ANOMALY",
+}
+
+// Builds an OMM from keyword/value pairs, which is what all three encodings
+// reduce to
+func ommFromFields(fields map[string]string) OMM {
+	omm := OMM{Fields: fields}
+
+	for _, keyword := range ommRequiredKeywords {
+		if fields[keyword] == "" {
+			omm.fail(keyword, fmt.Errorf("missing"))
+		}
+	}
+
+	omm.ObjectName = fields["OBJECT_NAME"]
+	omm.ObjectID = fields["OBJECT_ID"]
+	omm.CenterName = fields["CENTER_NAME"]
+	omm.RefFrame = fields["REF_FRAME"]
+	omm.TimeSystem = fields["TIME_SYSTEM"]
+	omm.MeanElementTheory = fields["MEAN_ELEMENT_THEORY"]
+	omm.Epoch = fields["EPOCH"]
+	omm.ClassificationType = fields["CLASSIFICATION_TYPE"]
+
+	omm.MeanMotion = omm.float(fields, "MEAN_MOTION")
+	omm.Eccentricity = omm.float(fields, "ECCENTRICITY")
+	omm.Inclination = omm.float(fields, "INCLINATION")
+	omm.RAAN = omm.float(fields, "RA_OF_ASC_NODE")
+	omm.ArgPericenter = omm.float(fields, "ARG_OF_PERICENTER")
+	omm.MeanAnomaly = omm.float(fields, "MEAN_ANOMALY")
+	omm.Bstar = omm.float(fields, "BSTAR")
+	omm.MeanMotionDot = omm.float(fields, "MEAN_MOTION_DOT")
+	omm.MeanMotionDDot = omm.float(fields, "MEAN_MOTION_DDOT")
+
+	omm.EphemerisType = omm.int(fields, "EPHEMERIS_TYPE")
+	omm.ElementSetNo = omm.int(fields, "ELEMENT_SET_NO")
+	omm.RevAtEpoch = omm.int(fields, "REV_AT_EPOCH")
+
+	if text := fields["NORAD_CAT_ID"]; text != "" {
+		if err := omm.NoradCatID.UnmarshalJSON([]byte(text)); err != nil {
+			omm.fail("NORAD_CAT_ID", err)
+		}
+	}
+
+	return omm
+}
+
+func (o *OMM) fail(keyword string, err error) {
+	if o.err == nil {
+		o.err = fmt.Errorf("OMM %s: %w", keyword, err)
+	}
+}
+
+func (o *OMM) float(fields map[string]string, keyword string) float64 {
+	text := fields[keyword]
+	if text == "" {
+		return 0
+	}
+	value, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		o.fail(keyword, fmt.Errorf("invalid number %q", text))
+	}
+	return value
+}
+
+func (o *OMM) int(fields map[string]string, keyword string) int {
+	text := fields[keyword]
+	if text == "" {
+		return 0
+	}
+	value, err := strconv.Atoi(text)
+	if err != nil {
+		o.fail(keyword, fmt.Errorf("invalid integer %q", text))
+	}
+	return value
+}
+
+// Validate reports reading errors and rejects messages that are not SGP4 mean
+// elements in TEME about the Earth
+func (o *OMM) Validate() error {
+	if o.err != nil {
+		return o.err
+	}
+
+	theory := strings.ToUpper(o.MeanElementTheory)
+	if theory != "" && theory != "SGP4" && theory != "SGP/SGP4" {
+		return fmt.Errorf("OMM MEAN_ELEMENT_THEORY: %s is not supported", o.MeanElementTheory)
+	}
+	if o.RefFrame != "" && strings.ToUpper(o.RefFrame) != "TEME" {
+		return fmt.Errorf("OMM REF_FRAME: %s is not supported", o.RefFrame)
+	}
+	if o.TimeSystem != "" && strings.ToUpper(o.TimeSystem) != "UTC" {
+		return fmt.Errorf("OMM TIME_SYSTEM: %s is not supported", o.TimeSystem)
+	}
+	if o.CenterName != "" && strings.ToUpper(o.CenterName) != "EARTH" {
+		return fmt.Errorf("OMM CENTER_NAME: %s is not supported", o.CenterName)
+	}
+	if o.MeanMotion <= 0 {
+		return fmt.Errorf("OMM MEAN_MOTION: must be positive")
+	}
+	if o.Eccentricity < 0 || o.Eccentricity >= 1 {
+		return fmt.Errorf("OMM ECCENTRICITY: %v is outside [0, 1)", o.Eccentricity)
+	}
+	if _, err := parseOMMEpoch(o.Epoch); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Identifier used in reports, NORAD_CAT_ID when present
+func (o *OMM) catalogID() string {
+	if o.NoradCatID != 0 {
+		return o.NoradCatID.String()
+	}
+	return o.ObjectID
+}
+
+var ommEpochLayouts = []string{
+	"2006-01-02T15:04:05.999999999Z07:00",
+	"2006-01-02T15:04:05.999999999",
+	"2006-002T15:04:05.999999999Z07:00",
+	"2006-002T15:04:05.999999999",
+}
+
+func parseOMMEpoch(text string) (time.Time, error) {
+	for _, layout := range ommEpochLayouts {
+		if epoch, err := time.Parse(layout, text); err == nil {
+			return epoch.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("OMM EPOCH: invalid timestamp %q", text)
+}
+
+func (o *OMM) sgp4Elements() (sgp4Elements, error) {
+	if err := o.Validate(); err != nil {
+		return sgp4Elements{}, err
+	}
+
+	epoch, _ := parseOMMEpoch(o.Epoch)
+	epochJD := timeToJulianDate(epoch)
+
+	deg2rad := math.Pi / 180.0
+	return sgp4Elements{
+		EpochJD:       epochJD.Day,
+		EpochFraction: epochJD.Fraction,
+		Bstar:         o.Bstar,
+		Inclination:   o.Inclination * deg2rad,
+		RAAN:          o.RAAN * deg2rad,
+		Eccentricity:  o.Eccentricity,
+		ArgPerigee:    o.ArgPericenter * deg2rad,
+		MeanAnomaly:   o.MeanAnomaly * deg2rad,
+		MeanMotion:    o.MeanMotion / xpdotp,
+	}, nil
+}
+
+// ReadOMMJSON reads a JSON array of OMM objects, or a single object, using
+// the CCSDS keywords as keys. Numbers may be quoted as Space-Track does.
+func ReadOMMJSON(r io.Reader) ([]OMM, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var objects []json.RawMessage
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		objects = []json.RawMessage{trimmed}
+	} else if err := json.Unmarshal(data, &objects); err != nil {
+		return nil, fmt.Errorf("reading OMM JSON: %w", err)
+	}
+
+	records := make([]OMM, 0, len(objects))
+	for i, object := range objects {
+		fields, err := jsonObjectFields(object)
+		if err != nil {
+			return nil, fmt.Errorf("reading OMM JSON record %d: %w", i, err)
+		}
+		records = append(records, ommFromFields(fields))
+	}
+	return records, nil
+}
+
+// Flattens a JSON object to keyword/value strings
+func jsonObjectFields(object json.RawMessage) (map[string]string, error) {
+	decoder := json.NewDecoder(bytes.NewReader(object))
+	decoder.UseNumber()
+
+	var values map[string]interface{}
+	if err := decoder.Decode(&values); err != nil {
+		return nil, err
+	}
+
+	fields := make(map[string]string, len(values))
+	for key, value := range values {
+		switch v := value.(type) {
+		case nil:
+			continue
+		case string:
+			fields[strings.ToUpper(key)] = strings.TrimSpace(v)
+		default:
+			fields[strings.ToUpper(key)] = fmt.Sprint(v)
+		}
+	}
+	return fields, nil
+}
+
+// ReadOMMXML reads either a single <omm> document or an <ndm> combining
+// several, collecting the keyword elements inside each <omm>
+func ReadOMMXML(r io.Reader) ([]OMM, error) {
+	decoder := xml.NewDecoder(r)
+
+	records := []OMM{}
+	var fields map[string]string
+	var text strings.Builder
+	var parameter string
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading OMM XML: %w", err)
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			if strings.EqualFold(t.Name.Local, "omm") {
+				fields = map[string]string{}
+			}
+			parameter = ""
+			if strings.EqualFold(t.Name.Local, "USER_DEFINED") {
+				for _, attr := range t.Attr {
+					if strings.EqualFold(attr.Name.Local, "parameter") {
+						parameter = strings.ToUpper(attr.Value)
+					}
+				}
+			}
+			text.Reset()
+		case xml.CharData:
+			text.Write(t)
+		case xml.EndElement:
+			name := strings.ToUpper(t.Name.Local)
+			switch {
+			case name == "OMM" && fields != nil:
+				records = append(records, ommFromFields(fields))
+				fields = nil
+			case fields != nil && name != "COMMENT":
+				// <USER_DEFINED parameter="OBJECT_TYPE"> is stored as OBJECT_TYPE
+				if name == "USER_DEFINED" {
+					name = parameter
+				}
+				if value := strings.TrimSpace(text.String()); value != "" && name != "" {
+					fields[name] = value
+				}
+			}
+			text.Reset()
+		}
+	}
+
+	if len(records) == 0 {
+		return nil, fmt.Errorf("reading OMM XML: no omm elements found")
+	}
+	return records, nil
+}
+
+// ReadOMMKVN reads keyword = value messages. Several messages can be
+// concatenated, each one starting with CCSDS_OMM_VERS.
+func ReadOMMKVN(r io.Reader) ([]OMM, error) {
+	scanner := bufio.NewScanner(r)
+
+	records := []OMM{}
+	fields := map[string]string{}
+	lineNumber := 0
+
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "COMMENT") {
+			continue
+		}
+
+		keyword, value, found := strings.Cut(line, "=")
+		if !found {
+			return nil, fmt.Errorf("reading OMM KVN line %d: expected KEYWORD = value", lineNumber)
+		}
+		keyword = strings.ToUpper(strings.TrimSpace(keyword))
+		value = strings.TrimSpace(value)
+
+		// Drop trailing units such as [rev/day]
+		if i := strings.Index(value, "["); i >= 0 && strings.HasSuffix(value, "]") {
+			value = strings.TrimSpace(value[:i])
+		}
+		keyword = strings.TrimPrefix(keyword, "USER_DEFINED_")
+
+		if keyword == "CCSDS_OMM_VERS" && len(fields) > 0 {
+			records = append(records, ommFromFields(fields))
+			fields = map[string]string{}
+		}
+		fields[keyword] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading OMM KVN: %w", err)
+	}
+
+	if len(fields) > 0 {
+		records = append(records, ommFromFields(fields))
+	}
+	return records, nil
+}
+
+// ReadOMM detects the encoding from the first non-blank character
+func ReadOMM(r io.Reader) ([]OMM, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	trimmed := bytes.TrimSpace(data)
+	switch {
+	case len(trimmed) == 0:
+		return nil, fmt.Errorf("reading OMM: empty input")
+	case trimmed[0] == '<':
+		return ReadOMMXML(bytes.NewReader(data))
+	case trimmed[0] == '[' || trimmed[0] == '{':
+		return ReadOMMJSON(bytes.NewReader(data))
+	default:
+		return ReadOMMKVN(bytes.NewReader(data))
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Same elements as SatTwoLineOne/SatTwoLineTwo
+const ommJSON = `[{
+	"OBJECT_NAME": "STARLINK-5990",
+	"OBJECT_ID": "2023-067N",
+	"EPOCH": "2025-01-11T02:52:53.932224",
+	"MEAN_MOTION": "15.02525502",
+	"ECCENTRICITY": 0.0001256,
+	"INCLINATION": 43.0052,
+	"RA_OF_ASC_NODE": 50.6716,
+	"ARG_OF_PERICENTER": 262.8432,
+	"MEAN_ANOMALY": 97.2268,
+	"EPHEMERIS_TYPE": 0,
+	"CLASSIFICATION_TYPE": "U",
+	"NORAD_CAT_ID": 56700,
+	"ELEMENT_SET_NO": 999,
+	"REV_AT_EPOCH": 9209,
+	"BSTAR": -4.8043e-5,
+	"MEAN_MOTION_DOT": -8.52e-6,
+	"MEAN_MOTION_DDOT": 0
+}]`
+
+const ommXML = `<?xml version="1.0" encoding="UTF-8"?>
+<ndm xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance">
+<omm id="CCSDS_OMM_VERS" version="2.0">
+<header><CREATION_DATE/><ORIGINATOR/></header>
+<body><segment>
+<metadata>
+<OBJECT_NAME>STARLINK-5990</OBJECT_NAME><OBJECT_ID>2023-067N</OBJECT_ID><CENTER_NAME>EARTH</CENTER_NAME>
+<REF_FRAME>TEME</REF_FRAME><TIME_SYSTEM>UTC</TIME_SYSTEM><MEAN_ELEMENT_THEORY>SGP4</MEAN_ELEMENT_THEORY>
+</metadata>
+<data>
+<meanElements>
+<EPOCH>2025-01-11T02:52:53.932224</EPOCH><MEAN_MOTION>15.02525502</MEAN_MOTION><ECCENTRICITY>.0001256</ECCENTRICITY>
+<INCLINATION>43.0052</INCLINATION><RA_OF_ASC_NODE>50.6716</RA_OF_ASC_NODE><ARG_OF_PERICENTER>262.8432</ARG_OF_PERICENTER>
+<MEAN_ANOMALY>97.2268</MEAN_ANOMALY>
+</meanElements>
+<tleParameters>
+<EPHEMERIS_TYPE>0</EPHEMERIS_TYPE><CLASSIFICATION_TYPE>U</CLASSIFICATION_TYPE><NORAD_CAT_ID>56700</NORAD_CAT_ID>
+<ELEMENT_SET_NO>999</ELEMENT_SET_NO><REV_AT_EPOCH>9209</REV_AT_EPOCH><BSTAR>-.48043E-4</BSTAR>
+<MEAN_MOTION_DOT>-.852E-5</MEAN_MOTION_DOT><MEAN_MOTION_DDOT>0</MEAN_MOTION_DDOT>
+</tleParameters>
+</data>
+</segment></body>
+</omm>
+</ndm>`
+
+const ommKVN = `CCSDS_OMM_VERS = 2.0
+COMMENT Generated for testing
+CREATION_DATE = 2025-01-11T12:00:00
+ORIGINATOR = TEST
+OBJECT_NAME = STARLINK-5990
+OBJECT_ID = 2023-067N
+CENTER_NAME = EARTH
+REF_FRAME = TEME
+TIME_SYSTEM = UTC
+MEAN_ELEMENT_THEORY = SGP4
+EPOCH = 2025-01-11T02:52:53.932224
+MEAN_MOTION = 15.02525502 [rev/day]
+ECCENTRICITY = 0.0001256
+INCLINATION = 43.0052 [deg]
+RA_OF_ASC_NODE = 50.6716 [deg]
+ARG_OF_PERICENTER = 262.8432 [deg]
+MEAN_ANOMALY = 97.2268 [deg]
+EPHEMERIS_TYPE = 0
+CLASSIFICATION_TYPE = U
+NORAD_CAT_ID = 56700
+ELEMENT_SET_NO = 999
+REV_AT_EPOCH = 9209
+BSTAR = -0.48043E-4 [1/ER]
+MEAN_MOTION_DOT = -0.852E-5 [rev/day**2]
+MEAN_MOTION_DDOT = 0.0 [rev/day**3]
+`
+
+func TestOMMEncodingsMatchTLE(t *testing.T) {
+	expected, _ := satTwo.propagateAtTime(CloseCollisionTime)
+
+	readers := map[string]func() ([]OMM, error){
+		"json": func() ([]OMM, error) { return ReadOMMJSON(strings.NewReader(ommJSON)) },
+		"xml":  func() ([]OMM, error) { return ReadOMMXML(strings.NewReader(ommXML)) },
+		"kvn":  func() ([]OMM, error) { return ReadOMMKVN(strings.NewReader(ommKVN)) },
+	}
+
+	for name, read := range readers {
+		records, err := read()
+		assert.NoError(t, err, name)
+		assert.Len(t, records, 1, name)
+		assert.NoError(t, records[0].Validate(), name)
+		assert.Equal(t, CatalogNumber(56700), records[0].NoradCatID, name)
+		assert.Equal(t, "2023-067N", records[0].ObjectID, name)
+
+		satellite, err := NewSgp4SatelliteFromOMM(&records[0])
+		assert.NoError(t, err, name)
+		assert.Equal(t, "56700", satellite.satelliteID(), name)
+
+		position, err := satellite.propagateAtTime(CloseCollisionTime)
+		assert.NoError(t, err, name)
+		assert.InDelta(t, expected.X, position.X, 1e-4, name)
+		assert.InDelta(t, expected.Y, position.Y, 1e-4, name)
+		assert.InDelta(t, expected.Z, position.Z, 1e-4, name)
+	}
+}
+
+func TestOMMKVNMultipleMessages(t *testing.T) {
+	second := strings.Replace(ommKVN, "NORAD_CAT_ID = 56700", "NORAD_CAT_ID = 270000001", 1)
+
+	records, err := ReadOMM(strings.NewReader(ommKVN + "\n" + second))
+	assert.NoError(t, err)
+	assert.Len(t, records, 2)
+	assert.Equal(t, CatalogNumber(270000001), records[1].NoradCatID)
+}
+
+func TestOMMValidation(t *testing.T) {
+	records, err := ReadOMMKVN(strings.NewReader(strings.Replace(ommKVN, "REF_FRAME = TEME", "REF_FRAME = GCRF", 1)))
+	assert.NoError(t, err)
+	assert.ErrorContains(t, records[0].Validate(), "REF_FRAME")
+
+	records, err = ReadOMMKVN(strings.NewReader(strings.Replace(ommKVN, "MEAN_MOTION = 15.02525502 [rev/day]\n", "", 1)))
+	assert.NoError(t, err)
+	assert.ErrorContains(t, records[0].Validate(), "MEAN_MOTION")
+
+	_, err = NewSgp4SatelliteFromOMM(&records[0])
+	var initErr *SatelliteInitError
+	assert.ErrorAs(t, err, &initErr)
+}
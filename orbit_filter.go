@@ -0,0 +1,424 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"sync"
+)
+
+// Stages of the orbit filter chain, in the order they run
+type FilterStage int
+
+const (
+	FilterApsis FilterStage = iota
+	FilterOrbitPath
+	FilterTime
+	numFilterStages
+)
+
+func (s FilterStage) String() string {
+	switch s {
+	case FilterApsis:
+		return "apogee/perigee"
+	case FilterOrbitPath:
+		return "orbit path"
+	case FilterTime:
+		return "time"
+	default:
+		return "passed"
+	}
+}
+
+// Part of the tier two search for a candidate pair, in place of the
+// default window either side of the sample
+type SearchWindow struct {
+	Start, End JulianDate
+}
+
+// Two-body orbit through a satellite's state, the frame is the perifocal
+// one: P towards perigee, W along the angular momentum and Q completing it
+type osculatingOrbit struct {
+	valid        bool
+	semiLatus    float64 // km
+	eccentricity float64
+	perigee      float64 // radius, km
+	apogee       float64 // radius, km
+	meanMotion   float64 // rad/s
+	meanAnomaly  float64 // rad, at the state's time
+	p, q, w      SatPosition
+}
+
+func orbitFromState(state SatState) osculatingOrbit {
+	r := state.Position
+	v := SatPosition(state.Velocity)
+	radius := vectorLength(r)
+	h := crossProduct(r, v)
+	hLength := vectorLength(h)
+	energy := dotProduct(v, v)/2 - wgs72Mu/radius
+	if radius == 0 || hLength == 0 || energy >= 0 {
+		return osculatingOrbit{}
+	}
+
+	eccentricityVector := subtractPositions(scalePosition(crossProduct(v, h), 1/wgs72Mu), scalePosition(r, 1/radius))
+	eccentricity := vectorLength(eccentricityVector)
+	semiMajorAxis := -wgs72Mu / (2 * energy)
+
+	w := scalePosition(h, 1/hLength)
+	// Perigee is undefined on a circle, any in-plane direction will do
+	p := scalePosition(r, 1/radius)
+	if eccentricity > 1e-10 {
+		p = scalePosition(eccentricityVector, 1/eccentricity)
+	}
+	q := crossProduct(w, p)
+
+	return osculatingOrbit{
+		valid:        true,
+		semiLatus:    hLength * hLength / wgs72Mu,
+		eccentricity: eccentricity,
+		perigee:      semiMajorAxis * (1 - eccentricity),
+		apogee:       semiMajorAxis * (1 + eccentricity),
+		meanMotion:   math.Sqrt(wgs72Mu / (semiMajorAxis * semiMajorAxis * semiMajorAxis)),
+		meanAnomaly:  meanAnomalyFromTrue(math.Atan2(dotProduct(r, q), dotProduct(r, p)), eccentricity),
+		p:            p,
+		q:            q,
+		w:            w,
+	}
+}
+
+func (o osculatingOrbit) radiusAt(trueAnomaly float64) float64 {
+	return o.semiLatus / (1 + o.eccentricity*math.Cos(trueAnomaly))
+}
+
+// Smallest and largest radius along the arc of true anomaly centre±halfWidth
+func (o osculatingOrbit) radiusRange(centre, halfWidth float64) (float64, float64) {
+	r1, r2 := o.radiusAt(centre-halfWidth), o.radiusAt(centre+halfWidth)
+	low, high := math.Min(r1, r2), math.Max(r1, r2)
+	if wrapAngle(-(centre - halfWidth)) <= 2*halfWidth {
+		low = o.perigee
+	}
+	if wrapAngle(math.Pi-(centre-halfWidth)) <= 2*halfWidth {
+		high = o.apogee
+	}
+	return low, high
+}
+
+// Spans of seconds from the state's time, within [from, to], when the
+// satellite is on the arc of true anomaly centre±halfWidth
+func (o osculatingOrbit) arcPassages(centre, halfWidth, from, to float64) [][2]float64 {
+	period := 2 * math.Pi / o.meanMotion
+	start := wrapAngle(meanAnomalyFromTrue(centre-halfWidth, o.eccentricity)-o.meanAnomaly) / o.meanMotion
+	duration := wrapAngle(meanAnomalyFromTrue(centre+halfWidth, o.eccentricity)-meanAnomalyFromTrue(centre-halfWidth, o.eccentricity)) / o.meanMotion
+
+	passages := [][2]float64{}
+	first := math.Floor((from - start - duration) / period)
+	for k := first; start+k*period <= to; k++ {
+		passageStart := start + k*period
+		if passageStart+duration >= from {
+			passages = append(passages, [2]float64{math.Max(passageStart, from), math.Min(passageStart+duration, to)})
+		}
+	}
+	return passages
+}
+
+func meanAnomalyFromTrue(trueAnomaly, eccentricity float64) float64 {
+	sin, cos := math.Sincos(trueAnomaly)
+	eccentricAnomaly := math.Atan2(math.Sqrt(1-eccentricity*eccentricity)*sin, eccentricity+cos)
+	return eccentricAnomaly - eccentricity*math.Sin(eccentricAnomaly)
+}
+
+// Angle wrapped to [0, 2π)
+func wrapAngle(angle float64) float64 {
+	angle = math.Mod(angle, 2*math.Pi)
+	if angle < 0 {
+		angle += 2 * math.Pi
+	}
+	return angle
+}
+
+// orbitFilterPair runs the filter chain on a pair whose orbits pass through
+// the given states. The orbits are two-body so threshold must carry a pad
+// for the perturbations over the span [from, to], in seconds from the
+// states' time. Returns the stage that removed the pair, or numFilterStages
+// and the part of the span the pair can be within threshold.
+func orbitFilterPair(o1, o2 osculatingOrbit, threshold, from, to float64) (FilterStage, float64, float64) {
+	if !o1.valid || !o2.valid {
+		return numFilterStages, from, to
+	}
+
+	// Apogee/perigee: the radius bands of the two orbits must overlap
+	if math.Max(o1.perigee, o2.perigee)-math.Min(o1.apogee, o2.apogee) > threshold {
+		return FilterApsis, 0, 0
+	}
+
+	// A satellite further than threshold from the other's plane cannot be
+	// within threshold of it. Both are near the line of nodes, within w of
+	// it measured from the centre of the earth.
+	node := crossProduct(o1.w, o2.w)
+	sinRelativeInclination := vectorLength(node)
+	w1 := math.Asin(math.Min(1, threshold/(o1.perigee*sinRelativeInclination)))
+	w2 := math.Asin(math.Min(1, threshold/(o2.perigee*sinRelativeInclination)))
+	// Near coplanar orbits are close along their whole length, and wide
+	// arcs around the two nodes overlap
+	if sinRelativeInclination == 0 || w1 >= math.Pi/4 || w2 >= math.Pi/4 {
+		return numFilterStages, from, to
+	}
+	node = scalePosition(node, 1/sinRelativeInclination)
+
+	removedBy := FilterOrbitPath
+	start, end := math.Inf(1), math.Inf(-1)
+	for _, direction := range []SatPosition{node, scalePosition(node, -1)} {
+		centre1 := math.Atan2(dotProduct(direction, o1.q), dotProduct(direction, o1.p))
+		centre2 := math.Atan2(dotProduct(direction, o2.q), dotProduct(direction, o2.p))
+
+		// Orbit path: the radii on the arcs around this node must come
+		// within threshold
+		low1, high1 := o1.radiusRange(centre1, w1)
+		low2, high2 := o2.radiusRange(centre2, w2)
+		if low2-high1 > threshold || low1-high2 > threshold {
+			continue
+		}
+		removedBy = FilterTime
+
+		// Time: both satellites must be on their arcs at once
+		for _, passage1 := range o1.arcPassages(centre1, w1, from, to) {
+			for _, passage2 := range o2.arcPassages(centre2, w2, from, to) {
+				overlapStart, overlapEnd := math.Max(passage1[0], passage2[0]), math.Min(passage1[1], passage2[1])
+				if overlapStart <= overlapEnd {
+					start, end = math.Min(start, overlapStart), math.Max(end, overlapEnd)
+				}
+			}
+		}
+	}
+
+	if start > end {
+		return removedBy, 0, 0
+	}
+	return numFilterStages, start, end
+}
+
+// Width of the altitude shells of bandedIndex, in km
+const altitudeShellWidth = 500
+
+// AltitudeBand is the range of radius, in km, a satellite can be at over the
+// screening. Two satellites can only come close if their bands overlap.
+type AltitudeBand struct {
+	Low, High float64
+}
+
+func (b AltitudeBand) overlaps(other AltitudeBand) bool {
+	return b.Low <= other.High && other.Low <= b.High
+}
+
+// altitudeBands runs the apogee/perigee stage ahead of tier one. Each band
+// spans the lowest perigee to the highest apogee of the satellite's
+// osculating orbits at every sample, so an orbit that decays or changes shape
+// during the window keeps all of it. Bands are widened by half the threshold
+// either side so overlapping bands are those within threshold, which also
+// covers the perturbations over a step past the apsides of each sample.
+func altitudeBands(ephemeris *Ephemeris, threshold float64) []AltitudeBand {
+	bands := make([]AltitudeBand, len(ephemeris.Apsides))
+	for i, apsides := range ephemeris.Apsides {
+		bands[i] = AltitudeBand{Low: apsides.Low - threshold/2, High: apsides.High + threshold/2}
+	}
+	return bands
+}
+
+// disjointBandPairs counts the pairs of satellites the bands rule out
+func disjointBandPairs(bands []AltitudeBand) int {
+	sorted := append([]AltitudeBand{}, bands...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Low < sorted[j].Low
+	})
+
+	// A band overlaps every later one that starts below its top
+	disjoint := 0
+	for i, band := range sorted {
+		overlapEnd := sort.Search(len(sorted), func(j int) bool { return sorted[j].Low > band.High })
+		disjoint += len(sorted) - max(overlapEnd, i+1)
+	}
+	return disjoint
+}
+
+// bandedIndex splits the satellites into altitude shells and runs the index
+// on each shell alone. A satellite joins every shell its band touches, so
+// satellites with overlapping bands share one, and pairs from a shared shell
+// are dropped when their bands are apart.
+type bandedIndex struct {
+	index SpatialIndex
+	bands []AltitudeBand
+}
+
+func (b bandedIndex) closePairs(points []IndexPoint, maxDist float64) []SatPair {
+	shells := map[int][]IndexPoint{}
+	unbounded := []IndexPoint{}
+	for _, point := range points {
+		band := b.bands[point.ID]
+		if math.IsInf(band.Low, 0) || math.IsInf(band.High, 0) {
+			unbounded = append(unbounded, point)
+			continue
+		}
+		for shell := int(math.Floor(band.Low / altitudeShellWidth)); shell <= int(math.Floor(band.High/altitudeShellWidth)); shell++ {
+			shells[shell] = append(shells[shell], point)
+		}
+	}
+	// Satellites without a band can meet any other
+	if len(unbounded) > 0 {
+		if len(shells) == 0 {
+			shells[0] = nil
+		}
+		for shell := range shells {
+			shells[shell] = append(shells[shell], unbounded...)
+		}
+	}
+
+	seen := map[SatPair]bool{}
+	pairs := []SatPair{}
+	for _, members := range shells {
+		for _, pair := range b.index.closePairs(members, maxDist) {
+			if seen[pair] || !b.bands[pair.ID1].overlaps(b.bands[pair.ID2]) {
+				continue
+			}
+			seen[pair] = true
+			pairs = append(pairs, pair)
+		}
+	}
+	return pairs
+}
+
+// Pairs removed by each stage of the filter chain. A pair counts against
+// the furthest stage it reached at any of its candidate times, and the pairs
+// the altitude bands kept out of tier one count against apogee/perigee.
+type FilterCounts struct {
+	Removed [numFilterStages]int
+	Passed  int
+}
+
+func (c *FilterCounts) add(other FilterCounts) {
+	for stage := range c.Removed {
+		c.Removed[stage] += other.Removed[stage]
+	}
+	c.Passed += other.Passed
+}
+
+func (c FilterCounts) print(out io.Writer) {
+	for stage := FilterStage(0); stage < numFilterStages; stage++ {
+		fmt.Fprintln(out, "Orbit filter", stage, "removed", c.Removed[stage], "pairs")
+	}
+	fmt.Fprintln(out, "Orbit filters passed", c.Passed, "pairs")
+}
+
+// orbitFiltersWithWorkerPool runs the filter chain over the tier one
+// candidates of each time. The orbits are fitted to the satellites' states
+// at that time and the pairs that pass keep only the part of the search span
+// in which they can come within the tier one distance, so a pair is only
+// refined at the samples where the time filter allows it. The span is the
+// swept interval, padded by a scan step, or else the tier two window.
+func orbitFiltersWithWorkerPool(atRiskPairs [][]SatPair, julianTimes []JulianDate, satellites []Propagator, config Config) ([][]SatPair, [][]SearchWindow, FilterCounts) {
+
+	numWorkers := workerCount(config.TierTwo.Workers)
+	threshold := config.TierOne.MaxDistance + config.Prefilter.Pad
+	window := config.TierTwo.Window.Seconds()
+	scan := config.TierTwo.ScanStep.Seconds()
+
+	tasks := make(chan int, len(atRiskPairs))
+	passed := make([][]SatPair, len(atRiskPairs))
+	windows := make([][]SearchWindow, len(atRiskPairs))
+	reached := make([][]FilterStage, len(atRiskPairs))
+	var wg sync.WaitGroup
+
+	worker := func() {
+		for i := range tasks {
+			// Satellites are in many pairs at a time, fit each once
+			orbits := map[int]osculatingOrbit{}
+			orbitAt := func(id int) osculatingOrbit {
+				if orbit, ok := orbits[id]; ok {
+					return orbit
+				}
+				// A satellite that cannot be propagated is left to tier two
+				state, err := satellites[id].stateAtTime(julianTimes[i])
+				orbit := osculatingOrbit{}
+				if err == nil {
+					orbit = orbitFromState(state)
+				}
+				orbits[id] = orbit
+				return orbit
+			}
+
+			// A swept candidate can only come within range during its
+			// interval
+			from, to := -window, window
+			if config.TierOne.Swept {
+				next := min(i+1, len(julianTimes)-1)
+				from, to = -scan, differenceInSeconds(julianTimes[i], julianTimes[next])+scan
+				// Nothing is sampled after the last time, the search runs
+				// on past it as it would without the filter
+				if next == len(julianTimes)-1 {
+					to = math.Max(to, window)
+				}
+			}
+
+			reached[i] = make([]FilterStage, len(atRiskPairs[i]))
+			for j, pair := range atRiskPairs[i] {
+				stage, start, end := orbitFilterPair(orbitAt(pair.ID1), orbitAt(pair.ID2), threshold, from, to)
+				reached[i][j] = stage
+				if stage == numFilterStages {
+					passed[i] = append(passed[i], pair)
+					windows[i] = append(windows[i], SearchWindow{
+						Start: julianDateAddSeconds(julianTimes[i], start),
+						End:   julianDateAddSeconds(julianTimes[i], end),
+					})
+				}
+			}
+		}
+		wg.Done()
+	}
+
+	for w := 0; w < numWorkers; w++ {
+		wg.Add(1)
+		go worker()
+	}
+
+	for i := range atRiskPairs {
+		tasks <- i
+	}
+	close(tasks)
+
+	wg.Wait()
+
+	furthest := map[SatPair]FilterStage{}
+	for i, pairs := range atRiskPairs {
+		for j, pair := range pairs {
+			if stage, ok := furthest[pair]; !ok || reached[i][j] > stage {
+				furthest[pair] = reached[i][j]
+			}
+		}
+	}
+	counts := FilterCounts{}
+	for _, stage := range furthest {
+		if stage == numFilterStages {
+			counts.Passed++
+		} else {
+			counts.Removed[stage]++
+		}
+	}
+
+	return passed, windows, counts
+}
+
+func dotProduct(a, b SatPosition) float64 {
+	return a.X*b.X + a.Y*b.Y + a.Z*b.Z
+}
+
+func crossProduct(a, b SatPosition) SatPosition {
+	return SatPosition{X: a.Y*b.Z - a.Z*b.Y, Y: a.Z*b.X - a.X*b.Z, Z: a.X*b.Y - a.Y*b.X}
+}
+
+func scalePosition(a SatPosition, factor float64) SatPosition {
+	return SatPosition{X: a.X * factor, Y: a.Y * factor, Z: a.Z * factor}
+}
+
+func vectorLength(a SatPosition) float64 {
+	return math.Sqrt(dotProduct(a, a))
+}
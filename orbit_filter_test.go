@@ -0,0 +1,230 @@
+package main
+
+import (
+	"io"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Orbit through perigee at the given radius on the x axis, in the plane
+// tilted by inclination about it
+func perigeeOrbit(perigee, eccentricity, inclination float64) osculatingOrbit {
+	speed := math.Sqrt(wgs72Mu * (1 + eccentricity) / perigee)
+	sin, cos := math.Sincos(inclination)
+	return orbitFromState(SatState{
+		Position: SatPosition{X: perigee},
+		Velocity: SatVelocity{Y: speed * cos, Z: speed * sin},
+	})
+}
+
+// Circular orbit at phase radians past the x axis, the ascending node
+func circularOrbit(radius, inclination, phase float64) osculatingOrbit {
+	at := createJulianDate(2025, 1, 12, 0, 0, 0)
+	sinI, cosI := math.Sincos(inclination)
+	propagator := &circularPropagator{reference: at, radius: radius, u: SatPosition{X: 1}, v: SatPosition{Y: cosI, Z: sinI}, phase: phase}
+	state, _ := propagator.stateAtTime(at)
+	return orbitFromState(state)
+}
+
+func TestOrbitFilterStages(t *testing.T) {
+	threshold := 125.0
+
+	stage, _, _ := orbitFilterPair(circularOrbit(7000, 0, 0), circularOrbit(8000, 1, 0), threshold, -600, 600)
+	assert.Equal(t, FilterApsis, stage)
+
+	// Radius bands overlap but at both nodes the radii are 200 km apart
+	stage, _, _ = orbitFilterPair(circularOrbit(7000, 0, 0), perigeeOrbit(6800, 0.04, 1), threshold, -600, 600)
+	assert.Equal(t, FilterOrbitPath, stage)
+
+	// Same orbit radius, but the second reaches the node a quarter orbit later
+	stage, _, _ = orbitFilterPair(circularOrbit(7000, 0, 0), circularOrbit(7000, 1, -math.Pi/2), threshold, -600, 600)
+	assert.Equal(t, FilterTime, stage)
+
+	// Both at the node a minute from now, which the window has to keep
+	orbit := circularOrbit(7000, 0, 0)
+	phase := -orbit.meanMotion * 60
+	stage, start, end := orbitFilterPair(circularOrbit(7000, 0, phase), circularOrbit(7000, 1, phase), threshold, -600, 600)
+	assert.Equal(t, numFilterStages, stage)
+	assert.Less(t, start, 60.0)
+	assert.Greater(t, end, 60.0)
+	assert.Less(t, end-start, 120.0)
+
+	// Near coplanar orbits are left to tier two
+	stage, start, end = orbitFilterPair(circularOrbit(7000, 0, 0), circularOrbit(7050, 0.001, 2), threshold, -600, 600)
+	assert.Equal(t, numFilterStages, stage)
+	assert.Equal(t, []float64{-600, 600}, []float64{start, end})
+}
+
+func TestOrbitFiltersKeepEveryCloseApproach(t *testing.T) {
+	start := createJulianDate(2025, 1, 12, 0, 0, 0)
+	satellites := randomCircularOrbits(200, start, 5)
+
+	config := defaultConfig()
+	expected := fineClosePairs(satellites, stepTimes(start, 1201, 1), config.TierOne.MaxDistance)
+	assert.NotEmpty(t, expected)
+
+	times := stepTimes(start, 6, 240)
+	ephemeris := buildSatLocations(satellites, times, config.Ephemeris, io.Discard)
+	ephemeris.Bands = altitudeBands(ephemeris, config.TierOne.MaxDistance+config.Prefilter.Pad)
+	atRiskPairs := sweptCollisionsWithWorkerPool(len(times), len(satellites), ephemeris, times, config.TierOne)
+	passed, windows, counts := orbitFiltersWithWorkerPool(atRiskPairs, times, satellites, config)
+
+	candidates := len(flattenPairs(atRiskPairs))
+	assert.Equal(t, candidates, counts.Passed+counts.Removed[FilterApsis]+counts.Removed[FilterOrbitPath]+counts.Removed[FilterTime])
+	assert.Less(t, counts.Passed, candidates)
+
+	// Pairs are refined only within their swept interval
+	for i := 0; i < len(times)-2; i++ {
+		for _, window := range windows[i] {
+			assert.False(t, window.Start.before(julianDateAddSeconds(times[i], -60)))
+			assert.False(t, julianDateAddSeconds(times[i+1], 60).before(window.End))
+		}
+	}
+
+	// Each close approach lies in the window of one of the pair's samples
+	for pair, point := range expected {
+		covered := false
+		for i := range passed {
+			for j, candidate := range passed[i] {
+				if candidate == pair && !point.JulianTime.before(windows[i][j].Start) && !windows[i][j].End.before(point.JulianTime) {
+					covered = true
+				}
+			}
+		}
+		assert.True(t, covered, "lost %v at %s", pair, point.JulianTime)
+	}
+}
+
+// Equatorial ellipse whose eccentricity rises from low to high halfway
+// through span seconds and falls back, so the perigee dips mid-window while
+// the orbits at either end look alike
+type breathingPropagator struct {
+	reference     JulianDate
+	semiMajorAxis float64
+	low, high     float64
+	span          float64
+}
+
+func (b *breathingPropagator) satelliteID() string {
+	return "E"
+}
+
+func (b *breathingPropagator) epoch() JulianDate {
+	return b.reference
+}
+
+func (b *breathingPropagator) propagateAtTime(julianDate JulianDate) (SatPosition, error) {
+	state, err := b.stateAtTime(julianDate)
+	return state.Position, err
+}
+
+func (b *breathingPropagator) stateAtTime(julianDate JulianDate) (SatState, error) {
+	seconds := differenceInSeconds(b.reference, julianDate)
+	e := b.low + (b.high-b.low)*math.Sin(math.Pi*seconds/b.span)
+	meanAnomaly := math.Sqrt(wgs72Mu/(b.semiMajorAxis*b.semiMajorAxis*b.semiMajorAxis)) * seconds
+
+	eccentricAnomaly := meanAnomaly
+	for i := 0; i < 20; i++ {
+		eccentricAnomaly = meanAnomaly + e*math.Sin(eccentricAnomaly)
+	}
+	trueAnomaly := 2 * math.Atan2(math.Sqrt(1+e)*math.Sin(eccentricAnomaly/2), math.Sqrt(1-e)*math.Cos(eccentricAnomaly/2))
+	radius := b.semiMajorAxis * (1 - e*math.Cos(eccentricAnomaly))
+
+	// Radial and transverse speeds of the ellipse with the current shape
+	sin, cos := math.Sincos(trueAnomaly)
+	speed := math.Sqrt(wgs72Mu / (b.semiMajorAxis * (1 - e*e)))
+	radial, transverse := speed*e*sin, speed*(1+e*cos)
+	return SatState{
+		JulianTime: julianDate,
+		Position:   SatPosition{X: radius * cos, Y: radius * sin},
+		Velocity:   SatVelocity{X: radial*cos - transverse*sin, Y: radial*sin + transverse*cos},
+	}, nil
+}
+
+func TestAltitudeBandsFollowTheWholeWindow(t *testing.T) {
+	start := createJulianDate(2025, 1, 12, 0, 0, 0)
+	times := stepTimes(start, 91, 240)
+	span := differenceInSeconds(start, times[len(times)-1])
+
+	// Perigee 7164 km at both ends and 6912 km halfway, down to a circular
+	// orbit at 6912 km
+	eccentric := &breathingPropagator{reference: start, semiMajorAxis: 7200, low: 0.005, high: 0.04, span: span}
+	circular := &circularPropagator{id: "C", reference: start, radius: 6912, u: SatPosition{X: 1}, v: SatPosition{Y: 1}}
+	satellites := []Propagator{eccentric, circular}
+
+	config := defaultConfig()
+	threshold := config.TierOne.MaxDistance + config.Prefilter.Pad
+	for _, julianTime := range []JulianDate{times[0], times[len(times)-1]} {
+		state, _ := eccentric.stateAtTime(julianTime)
+		assert.InDelta(t, 7164, orbitFromState(state).perigee, 0.01)
+	}
+
+	// The ends alone put the bands 250 km apart
+	ephemeris := buildSatLocations(satellites, times, config.Ephemeris, io.Discard)
+	assert.InDelta(t, 6912, ephemeris.Apsides[0].Low, 0.5)
+	assert.InDelta(t, 7200*1.04, ephemeris.Apsides[0].High, 0.5)
+
+	bands := altitudeBands(ephemeris, threshold)
+	assert.True(t, bands[0].overlaps(bands[1]))
+	assert.Equal(t, 0, disjointBandPairs(bands))
+}
+
+// Counts the pairs of points handed to the index
+type countingIndex struct {
+	index SpatialIndex
+	pairs *int
+}
+
+func (c countingIndex) closePairs(points []IndexPoint, maxDist float64) []SatPair {
+	*c.pairs += len(points) * (len(points) - 1) / 2
+	return c.index.closePairs(points, maxDist)
+}
+
+func TestAltitudeBandsKeepPairsOutOfIndex(t *testing.T) {
+	start := createJulianDate(2025, 1, 12, 0, 0, 0)
+	// Two shells 1500 km apart
+	satellites := randomCircularOrbits(200, start, 7)
+	for _, satellite := range satellites[100:] {
+		satellite.(*circularPropagator).radius += 1500
+	}
+
+	config := defaultConfig()
+	times := stepTimes(start, 6, 240)
+	ephemeris := buildSatLocations(satellites, times, config.Ephemeris, io.Discard)
+	bands := altitudeBands(ephemeris, config.TierOne.MaxDistance+config.Prefilter.Pad)
+	assert.Equal(t, 100*100, disjointBandPairs(bands))
+
+	points := []IndexPoint{}
+	for i := range satellites {
+		points = append(points, IndexPoint{ID: i, Position: ephemeris.Positions[i][0]})
+	}
+
+	var plain, banded int
+	expected := countingIndex{index: newSpatialIndex(config.TierOne), pairs: &plain}.closePairs(points, config.TierOne.BoxSize)
+	actual := bandedIndex{index: countingIndex{index: newSpatialIndex(config.TierOne), pairs: &banded}, bands: bands}.closePairs(points, config.TierOne.BoxSize)
+	assert.Equal(t, 200*199/2, plain)
+	assert.Equal(t, 2*100*99/2, banded)
+
+	// Only the pairs across the shells are lost
+	sameShell := []SatPair{}
+	for _, pair := range expected {
+		if (pair.ID1 < 100) == (pair.ID2 < 100) {
+			sameShell = append(sameShell, pair)
+		}
+	}
+	assert.NotEmpty(t, sameShell)
+	assert.ElementsMatch(t, sameShell, actual)
+}
+
+func TestOrbitFilterKeepsSgp4CloseApproach(t *testing.T) {
+	sample := julianDateAddSeconds(CloseCollisionTime, -180)
+	state1, _ := satTwo.stateAtTime(sample)
+	state2, _ := satThree.stateAtTime(sample)
+
+	stage, start, end := orbitFilterPair(orbitFromState(state1), orbitFromState(state2), 125, -600, 600)
+	assert.Equal(t, numFilterStages, stage)
+	assert.Less(t, start, 180.0)
+	assert.Greater(t, end, 180.0)
+}
@@ -0,0 +1,319 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sync"
+)
+
+// 2D collision probability methods
+const (
+	PcFoster = "foster"
+	PcChan   = "chan"
+	PcAlfano = "alfano"
+)
+
+var pcMethods = []string{PcFoster, PcChan, PcAlfano}
+
+func validatePcMethod(method string) error {
+	for _, name := range pcMethods {
+		if method == name {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown method %q, expected one of %v", method, pcMethods)
+}
+
+// Position covariance in TEME, km^2
+type Covariance [3][3]float64
+
+// ricCovariance turns standard deviations along a satellite's radial,
+// in-track and cross-track axes, in km, into a TEME covariance
+func ricCovariance(state SatState, sigma RICVector) Covariance {
+	radial, inTrack, crossTrack := ricAxes(state)
+	axes := [3]SatPosition{radial, inTrack, crossTrack}
+	variances := [3]float64{sigma.Radial * sigma.Radial, sigma.InTrack * sigma.InTrack, sigma.CrossTrack * sigma.CrossTrack}
+
+	var covariance Covariance
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			for k, axis := range axes {
+				covariance[i][j] += variances[k] * component(axis, i) * component(axis, j)
+			}
+		}
+	}
+	return covariance
+}
+
+// Unit radial, in-track and cross-track axes of a satellite
+func ricAxes(state SatState) (SatPosition, SatPosition, SatPosition) {
+	radial := scalePosition(state.Position, 1/vectorLength(state.Position))
+	angularMomentum := crossProduct(state.Position, SatPosition(state.Velocity))
+	crossTrack := scalePosition(angularMomentum, 1/vectorLength(angularMomentum))
+	return radial, crossProduct(crossTrack, radial), crossTrack
+}
+
+func component(v SatPosition, i int) float64 {
+	return [3]float64{v.X, v.Y, v.Z}[i]
+}
+
+// The encounter at closest approach projected on the B-plane, normal to the
+// relative velocity. Distances in km.
+type BPlaneEncounter struct {
+	// Miss vector in the plane
+	Miss [2]float64
+	// Combined position covariance in the plane, km^2
+	Covariance [2][2]float64
+}
+
+// bPlaneProjection projects the miss vector and the sum of the two
+// covariances on the B-plane. The first axis is along the miss vector, the
+// second completes the plane.
+func bPlaneProjection(primary, secondary SatState, covariance1, covariance2 Covariance) BPlaneEncounter {
+	miss := subtractPositions(secondary.Position, primary.Position)
+	velocity := subtractPositions(SatPosition(secondary.Velocity), SatPosition(primary.Velocity))
+	normal := scalePosition(velocity, 1/vectorLength(velocity))
+
+	// At closest approach the miss is normal to the relative velocity, the
+	// component along it is removed for refinements that stop a little off
+	inPlane := subtractPositions(miss, scalePosition(normal, dotProduct(miss, normal)))
+	xAxis := SatPosition{}
+	if length := vectorLength(inPlane); length > 0 {
+		xAxis = scalePosition(inPlane, 1/length)
+	} else {
+		// Direct hit, any axis in the plane will do
+		helper := SatPosition{X: 1}
+		if math.Abs(normal.X) > 0.9 {
+			helper = SatPosition{Y: 1}
+		}
+		xAxis = crossProduct(normal, helper)
+		xAxis = scalePosition(xAxis, 1/vectorLength(xAxis))
+	}
+	zAxis := crossProduct(xAxis, normal)
+
+	axes := [2]SatPosition{xAxis, zAxis}
+	encounter := BPlaneEncounter{Miss: [2]float64{vectorLength(inPlane), 0}}
+	for a := 0; a < 2; a++ {
+		for b := 0; b < 2; b++ {
+			for i := 0; i < 3; i++ {
+				for j := 0; j < 3; j++ {
+					combined := covariance1[i][j] + covariance2[i][j]
+					encounter.Covariance[a][b] += component(axes[a], i) * combined * component(axes[b], j)
+				}
+			}
+		}
+	}
+	return encounter
+}
+
+// principalAxes rotates the encounter so the covariance is diagonal,
+// returning the miss and standard deviations along the principal axes
+func (e BPlaneEncounter) principalAxes() ([2]float64, [2]float64) {
+	a, b, c := e.Covariance[0][0], e.Covariance[0][1], e.Covariance[1][1]
+	angle := 0.5 * math.Atan2(2*b, a-c)
+	sin, cos := math.Sincos(angle)
+
+	variance1 := a*cos*cos + 2*b*sin*cos + c*sin*sin
+	variance2 := a*sin*sin - 2*b*sin*cos + c*cos*cos
+	miss := [2]float64{
+		e.Miss[0]*cos + e.Miss[1]*sin,
+		-e.Miss[0]*sin + e.Miss[1]*cos,
+	}
+	return miss, [2]float64{math.Sqrt(variance1), math.Sqrt(variance2)}
+}
+
+// collisionProbability is the 2D probability that the objects come within
+// hardBodyRadius km, assuming straight line relative motion through the
+// encounter
+func collisionProbability(encounter BPlaneEncounter, hardBodyRadius float64, method string) (float64, error) {
+	miss, sigma := encounter.principalAxes()
+	if sigma[0] <= 0 || sigma[1] <= 0 || math.IsNaN(sigma[0]) || math.IsNaN(sigma[1]) {
+		return 0, fmt.Errorf("covariance is not positive definite")
+	}
+
+	switch method {
+	case PcFoster:
+		return pcFoster(miss, sigma, hardBodyRadius), nil
+	case PcChan:
+		return pcChan(miss, sigma, hardBodyRadius), nil
+	case PcAlfano:
+		return pcAlfano(miss, sigma, hardBodyRadius), nil
+	}
+	return 0, validatePcMethod(method)
+}
+
+// Enough integration steps to resolve a density of width sigma over radius
+func pcSteps(radius, sigma float64) int {
+	steps := int(math.Ceil(8 * radius / sigma))
+	steps = max(50, min(steps, 5000))
+	return steps + steps%2
+}
+
+// pcFoster integrates the Gaussian density over the hard-body disk in polar
+// coordinates about its centre, Simpson's rule along the radius and the
+// trapezium rule, exact for periodic integrands, around it
+func pcFoster(miss, sigma [2]float64, radius float64) float64 {
+	radialSteps := pcSteps(radius, math.Min(sigma[0], sigma[1]))
+	angularSteps := 2 * radialSteps
+	h := radius / float64(radialSteps)
+	normalisation := 1 / (2 * math.Pi * sigma[0] * sigma[1])
+
+	ring := func(rho float64) float64 {
+		total := 0.0
+		for k := 0; k < angularSteps; k++ {
+			sin, cos := math.Sincos(2 * math.Pi * float64(k) / float64(angularSteps))
+			x := (miss[0] + rho*cos) / sigma[0]
+			y := (miss[1] + rho*sin) / sigma[1]
+			total += math.Exp(-(x*x + y*y) / 2)
+		}
+		return rho * total * 2 * math.Pi / float64(angularSteps)
+	}
+
+	sum := ring(0) + ring(radius)
+	for i := 1; i < radialSteps; i++ {
+		weight := 2.0
+		if i%2 == 1 {
+			weight = 4
+		}
+		sum += weight * ring(float64(i)*h)
+	}
+	return normalisation * sum * h / 3
+}
+
+// pcChan is Chan's series, exact for an isotropic covariance and otherwise
+// treating the disk as having the same area in the scaled plane
+func pcChan(miss, sigma [2]float64, radius float64) float64 {
+	u := (miss[0]*miss[0])/(sigma[0]*sigma[0]) + (miss[1]*miss[1])/(sigma[1]*sigma[1])
+	v := radius * radius / (sigma[0] * sigma[1])
+
+	// P = e^(-u/2) sum_m (u/2)^m/m! Q_m, Q_m = e^(-v/2) sum_k>m (v/2)^k/k!
+	// being the Poisson tail. Past the mode the tail is summed directly,
+	// 1 - e^(-v/2) sum_k<=m cancels to nothing for a small disk.
+	outer := math.Exp(-u / 2)
+	inner := math.Exp(-v / 2)
+	innerSum := inner
+	total := 0.0
+	for m := 0; m < 1000; m++ {
+		tail := 1 - innerSum
+		if float64(m+1) > v/2 {
+			tail = 0
+			for k, term := m+1, inner*v/2/float64(m+1); term > 1e-17*tail; k++ {
+				tail += term
+				term *= v / 2 / float64(k+1)
+			}
+		}
+
+		term := outer * tail
+		total += term
+		if float64(m) > u/2 && term <= 1e-16*total {
+			break
+		}
+		outer *= u / 2 / float64(m+1)
+		inner *= v / 2 / float64(m+1)
+		innerSum += inner
+	}
+	return total
+}
+
+// pcAlfano reduces the integral to one dimension with the error function
+// across the disk and integrates along the first principal axis with
+// Simpson's rule. Stepping in angle, x = -R cos(phi), keeps the integrand
+// smooth at the edges of the disk.
+func pcAlfano(miss, sigma [2]float64, radius float64) float64 {
+	steps := pcSteps(radius, sigma[0])
+	h := math.Pi / float64(steps)
+
+	strip := func(phi float64) float64 {
+		sin, cos := math.Sincos(phi)
+		halfChord := radius * sin
+		across := erfDifference((miss[1]+halfChord)/(sigma[1]*math.Sqrt2), (miss[1]-halfChord)/(sigma[1]*math.Sqrt2))
+		along := (-radius*cos - miss[0]) / sigma[0]
+		return across * math.Exp(-along*along/2) * radius * sin
+	}
+
+	sum := strip(0) + strip(math.Pi)
+	for i := 1; i < steps; i++ {
+		weight := 2.0
+		if i%2 == 1 {
+			weight = 4
+		}
+		sum += weight * strip(float64(i)*h)
+	}
+	return sum * h / 3 / (sigma[0] * math.Sqrt(8*math.Pi))
+}
+
+// erf(a) - erf(b) for a >= b. In the tails both round to one, the
+// complementary function keeps the difference.
+func erfDifference(a, b float64) float64 {
+	switch {
+	case b > 0:
+		return math.Erfc(b) - math.Erfc(a)
+	case a < 0:
+		return math.Erfc(-a) - math.Erfc(-b)
+	}
+	return math.Erf(a) - math.Erf(b)
+}
+
+// Collision probability of an event with the configured covariance
+func eventPc(event MinDistancePoint, config PcConfig) (float64, error) {
+	covariance1 := ricCovariance(event.Primary, config.Sigma)
+	covariance2 := ricCovariance(event.Secondary, config.Sigma)
+	encounter := bPlaneProjection(event.Primary, event.Secondary, covariance1, covariance2)
+	return collisionProbability(encounter, config.HardBodyRadius, config.Method)
+}
+
+// attachPc computes the collision probability of every event in the store
+// from the states at closest approach, spread over a worker pool. Events
+// whose probability cannot be computed keep a NaN Pc, their count and the
+// first error are returned.
+func (s *EventStore) attachPc(config PcConfig, workers int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tasks := make(chan []MinDistancePoint, len(s.pairs))
+	var wg sync.WaitGroup
+	var failuresMu sync.Mutex
+	failed := 0
+	var firstErr error
+
+	worker := func() {
+		for events := range tasks {
+			for i := range events {
+				pc, err := eventPc(events[i], config)
+				if err != nil {
+					pc = math.NaN()
+					failuresMu.Lock()
+					if failed == 0 {
+						firstErr = err
+					}
+					failed++
+					failuresMu.Unlock()
+				}
+				events[i].Pc = pc
+			}
+		}
+		wg.Done()
+	}
+
+	for w := 0; w < workerCount(workers); w++ {
+		wg.Add(1)
+		go worker()
+	}
+
+	for _, events := range s.pairs {
+		tasks <- events
+	}
+	close(tasks)
+
+	wg.Wait()
+	return failed, firstErr
+}
+
+// pcRanksBefore orders probabilities most likely first, with the events
+// that have none last
+func pcRanksBefore(a, b float64) bool {
+	if math.IsNaN(b) {
+		return !math.IsNaN(a)
+	}
+	return a > b
+}
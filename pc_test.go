@@ -0,0 +1,136 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Marcum Q function Q1(a, b) by its series, the probability that a 2D unit
+// Gaussian offset by a lands outside radius b
+func marcumQ1(a, b float64) float64 {
+	total := 0.0
+	poisson := math.Exp(-a * a / 2)
+	for k := 0; k < 200; k++ {
+		// P(chi-square with 2k+2 dof > b^2) for each Poisson term
+		tail, term := 0.0, math.Exp(-b*b/2)
+		for j := 0; j <= k; j++ {
+			tail += term
+			term *= b * b / 2 / float64(j+1)
+		}
+		total += poisson * tail
+		poisson *= a * a / 2 / float64(k+1)
+	}
+	return total
+}
+
+func TestPcIsotropicClosedForm(t *testing.T) {
+	sigma := [2]float64{0.1, 0.1}
+	radius := 0.02
+
+	// Centred on the disk, P = 1 - exp(-R^2 / 2 sigma^2)
+	expected := 1 - math.Exp(-radius*radius/(2*sigma[0]*sigma[0]))
+	assert.InDelta(t, expected, pcFoster([2]float64{}, sigma, radius), 1e-8)
+	assert.InDelta(t, expected, pcChan([2]float64{}, sigma, radius), 1e-12)
+	assert.InDelta(t, expected, pcAlfano([2]float64{}, sigma, radius), 1e-8)
+
+	// Offset, the Rician distribution of the miss
+	miss := [2]float64{0.15, 0.05}
+	offset := math.Hypot(miss[0], miss[1]) / sigma[0]
+	expected = 1 - marcumQ1(offset, radius/sigma[0])
+	for _, pc := range []float64{pcFoster(miss, sigma, radius), pcChan(miss, sigma, radius), pcAlfano(miss, sigma, radius)} {
+		assert.InEpsilon(t, expected, pc, 1e-6)
+	}
+}
+
+func TestPcMethodsAgreeOnElongatedCovariance(t *testing.T) {
+	random := rand.New(rand.NewSource(7))
+
+	for _, c := range []struct {
+		miss   [2]float64
+		sigma  [2]float64
+		radius float64
+	}{
+		{[2]float64{0.3, 0}, [2]float64{0.5, 0.05}, 0.02},
+		{[2]float64{0.2, 0.1}, [2]float64{1, 0.2}, 0.05},
+		{[2]float64{0, 0.05}, [2]float64{0.03, 0.2}, 0.01},
+	} {
+		foster := pcFoster(c.miss, c.sigma, c.radius)
+		assert.InEpsilon(t, foster, pcAlfano(c.miss, c.sigma, c.radius), 1e-6)
+		// Chan's equal area disk is an approximation when elongated
+		assert.InEpsilon(t, foster, pcChan(c.miss, c.sigma, c.radius), 0.05)
+
+		// Sampling the Gaussian directly
+		samples, hits := 400000, 0
+		for i := 0; i < samples; i++ {
+			x := c.sigma[0]*random.NormFloat64() - c.miss[0]
+			y := c.sigma[1]*random.NormFloat64() - c.miss[1]
+			if x*x+y*y <= c.radius*c.radius {
+				hits++
+			}
+		}
+		estimate := float64(hits) / float64(samples)
+		standardError := math.Sqrt(foster * (1 - foster) / float64(samples))
+		assert.InDelta(t, foster, estimate, 4*standardError)
+	}
+}
+
+func TestPcPublishedTestCases(t *testing.T) {
+	// Chan's short-term encounter test cases (Spacecraft Collision
+	// Probability, 2008), Pc tabulated to four figures
+	for i, c := range []struct {
+		sigma  [2]float64
+		miss   [2]float64
+		radius float64
+		pc     float64
+	}{
+		{[2]float64{50, 25}, [2]float64{10, 0}, 5, 9.742e-3},
+		{[2]float64{50, 25}, [2]float64{0, 10}, 5, 9.181e-3},
+		{[2]float64{75, 25}, [2]float64{10, 0}, 5, 6.571e-3},
+		{[2]float64{75, 25}, [2]float64{0, 10}, 5, 6.125e-3},
+		{[2]float64{3000, 1000}, [2]float64{1000, 0}, 10, 1.577e-5},
+		{[2]float64{3000, 1000}, [2]float64{0, 1000}, 10, 1.011e-5},
+		{[2]float64{3000, 1000}, [2]float64{10000, 0}, 10, 6.443e-8},
+		{[2]float64{3000, 1000}, [2]float64{0, 10000}, 10, 3.219e-27},
+	} {
+		assert.InEpsilon(t, c.pc, pcFoster(c.miss, c.sigma, c.radius), 1e-3, "case %d", i+1)
+		assert.InEpsilon(t, c.pc, pcAlfano(c.miss, c.sigma, c.radius), 1e-3, "case %d", i+1)
+		// The equal area disk is off by a few tenths of a percent where the
+		// disk is large against the narrow axis
+		assert.InEpsilon(t, c.pc, pcChan(c.miss, c.sigma, c.radius), 5e-3, "case %d", i+1)
+	}
+
+	// Elongated three to one and offset along the narrow axis, the equal
+	// area disk is still close but no longer exact
+	chan4 := pcChan([2]float64{0, 10}, [2]float64{75, 25}, 5)
+	assert.InEpsilon(t, 6.125e-3, chan4, 3e-3)
+	assert.Greater(t, math.Abs(chan4-6.125e-3)/6.125e-3, 1e-3)
+}
+
+func TestBPlaneProjection(t *testing.T) {
+	// Head on along y, missing by 0.1 km radially and 0.05 km out of plane
+	primary := SatState{Position: SatPosition{X: 7000}, Velocity: SatVelocity{Y: 7.5}}
+	secondary := SatState{Position: SatPosition{X: 7000.1, Z: 0.05}, Velocity: SatVelocity{Y: -7.5}}
+
+	sigma := RICVector{Radial: 0.1, InTrack: 1, CrossTrack: 0.3}
+	encounter := bPlaneProjection(primary, secondary, ricCovariance(primary, sigma), ricCovariance(secondary, sigma))
+	assert.InDelta(t, math.Hypot(0.1, 0.05), encounter.Miss[0], 1e-12)
+	assert.Equal(t, 0.0, encounter.Miss[1])
+
+	// In-track is along the relative velocity and drops out, radial and
+	// cross-track variances add
+	miss, deviations := encounter.principalAxes()
+	assert.InDelta(t, math.Hypot(0.1, 0.05), math.Hypot(miss[0], miss[1]), 1e-12)
+	low, high := math.Min(deviations[0], deviations[1]), math.Max(deviations[0], deviations[1])
+	assert.InDelta(t, math.Sqrt(2*0.01), low, 1e-9)
+	assert.InDelta(t, math.Sqrt(2*0.09), high, 1e-9)
+
+	pc, err := collisionProbability(encounter, 0.02, PcFoster)
+	assert.NoError(t, err)
+	assert.InEpsilon(t, pcFoster([2]float64{0.1, 0.05}, [2]float64{math.Sqrt(0.02), math.Sqrt(0.18)}, 0.02), pc, 1e-6)
+
+	_, err = collisionProbability(encounter, 0.02, "guess")
+	assert.Error(t, err)
+}
@@ -0,0 +1,63 @@
+package main
+
+import "fmt"
+
+type SatPosition struct {
+	X float64
+	Y float64
+	Z float64
+}
+
+type SatVelocity struct {
+	X float64
+	Y float64
+	Z float64
+}
+
+// Full TEME state vector, position in km and velocity in km/s
+type SatState struct {
+	JulianTime JulianDate
+	Position   SatPosition
+	Velocity   SatVelocity
+}
+
+// SGP4 mean Keplerian elements at a point in time, distances in km and
+// angles in degrees
+type MeanElements struct {
+	SemiMajorAxis float64
+	Eccentricity  float64
+	Inclination   float64
+	RAAN          float64
+	ArgPerigee    float64
+	MeanAnomaly   float64
+}
+
+// Propagator is anything that can produce a satellite position at a point in
+// time: the space-track library, the native SGP4 port, an ephemeris
+// interpolator or a fake in tests. The screening code only depends on this.
+type Propagator interface {
+	// Catalog identifier of the object, e.g. the NORAD catalog number
+	satelliteID() string
+	// Julian date (UTC) of the element set the propagator was built from
+	epoch() JulianDate
+	// TEME position in km at a Julian date (UTC)
+	propagateAtTime(julianDate JulianDate) (SatPosition, error)
+	// TEME position and velocity at a Julian date (UTC)
+	stateAtTime(julianDate JulianDate) (SatState, error)
+}
+
+// Optionally implemented by propagators that are driven by mean elements
+type MeanElementsPropagator interface {
+	meanElementsAtTime(julianDate JulianDate) (MeanElements, error)
+}
+
+// Returned when a propagator cannot be built from an element set, carrying
+// the message from the underlying library
+type SatelliteInitError struct {
+	ObjectID string
+	Message  string
+}
+
+func (e *SatelliteInitError) Error() string {
+	return fmt.Sprintf("error initializing SGP4 for %s: %s", e.ObjectID, e.Message)
+}
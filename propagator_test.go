@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Moves in a straight line at constant velocity, positions in km and
+// velocities in km/s relative to the reference Julian date
+type linearPropagator struct {
+	id        string
+	reference JulianDate
+	position  SatPosition
+	velocity  SatPosition
+}
+
+func (l *linearPropagator) satelliteID() string {
+	return l.id
+}
+
+func (l *linearPropagator) epoch() JulianDate {
+	return l.reference
+}
+
+func (l *linearPropagator) propagateAtTime(julianDate JulianDate) (SatPosition, error) {
+	seconds := differenceInSeconds(l.reference, julianDate)
+	return SatPosition{
+		X: l.position.X + l.velocity.X*seconds,
+		Y: l.position.Y + l.velocity.Y*seconds,
+		Z: l.position.Z + l.velocity.Z*seconds,
+	}, nil
+}
+
+func (l *linearPropagator) stateAtTime(julianDate JulianDate) (SatState, error) {
+	position, _ := l.propagateAtTime(julianDate)
+	return SatState{
+		JulianTime: julianDate,
+		Position:   position,
+		Velocity:   SatVelocity(l.velocity),
+	}, nil
+}
+
+func TestScreeningWithFakePropagators(t *testing.T) {
+	tca := createJulianDate(2025, 1, 12, 6, 0, 0)
+
+	satellites := []Propagator{
+		&linearPropagator{id: "A", reference: tca, position: SatPosition{X: 7000}, velocity: SatPosition{Y: 0.5}},
+		&linearPropagator{id: "B", reference: tca, position: SatPosition{X: 7001}, velocity: SatPosition{Y: -0.5}},
+		&linearPropagator{id: "C", reference: tca, position: SatPosition{X: -7000}, velocity: SatPosition{Z: 7}},
+	}
+
+	times := []JulianDate{}
+	for i := -3; i <= 3; i++ {
+		times = append(times, julianDateAddSeconds(tca, float64(i)*60))
+	}
+
+	config := defaultConfig()
+	ephemeris := buildSatLocations(satellites, times, config.Ephemeris, io.Discard)
+	atRiskPairs := tierOneCollisionsWithWorkerPool(len(times), len(satellites), ephemeris, config.TierOne, io.Discard)
+	events := tierTwoCollisionsWithWorkerPool(atRiskPairs, nil, times, satellites, config.TierTwo)
+
+	top := events.getTopPairs(1)
+	assert.Equal(t, 0, top[0].Sat1ID)
+	assert.Equal(t, 1, top[0].Sat2ID)
+	assert.Equal(t, "A", top[0].Sat1CatalogID)
+	assert.Equal(t, "B", top[0].Sat2CatalogID)
+	assert.InDelta(t, 1.0, top[0].Distance, 0.01)
+	assert.InDelta(t, 0.0, differenceInSeconds(tca, top[0].JulianTime), 0.1)
+}
+
+// Fails for every time after the cutoff, like a satellite that decays
+type failingPropagator struct {
+	linearPropagator
+	cutoff JulianDate
+}
+
+func (f *failingPropagator) propagateAtTime(julianDate JulianDate) (SatPosition, error) {
+	if f.cutoff.before(julianDate) {
+		return SatPosition{}, fmt.Errorf("propagation error: %w", errSgp4Decayed)
+	}
+	return f.linearPropagator.propagateAtTime(julianDate)
+}
+
+func (f *failingPropagator) stateAtTime(julianDate JulianDate) (SatState, error) {
+	if f.cutoff.before(julianDate) {
+		return SatState{}, fmt.Errorf("propagation error: %w", errSgp4Decayed)
+	}
+	return f.linearPropagator.stateAtTime(julianDate)
+}
+
+func TestBuildSatLocationsInParallel(t *testing.T) {
+	start := createJulianDate(2025, 1, 12, 0, 0, 0)
+	times := []JulianDate{}
+	for i := 0; i < 30; i++ {
+		times = append(times, julianDateAddSeconds(start, float64(i)*240))
+	}
+
+	satellites := []Propagator{satOne, satTwo, satThree}
+	for i := 0; i < 20; i++ {
+		satellites = append(satellites, &failingPropagator{
+			linearPropagator: linearPropagator{id: fmt.Sprint(i), reference: start, position: SatPosition{X: 7000}, velocity: SatPosition{Y: 7}},
+			cutoff:           times[i],
+		})
+	}
+
+	serial := buildSatLocations(satellites, times, EphemerisConfig{Workers: 1}, io.Discard)
+	parallel := buildSatLocations(satellites, times, EphemerisConfig{Workers: 8}, io.Discard)
+
+	assert.Equal(t, serial, parallel)
+	for i, expected := range []int{0, 0, 0, 29, 28} {
+		assert.Equal(t, expected, parallel.Failures[i].total())
+	}
+	assert.Equal(t, 28, parallel.Failures[4].Counts[FailureDecayed])
+	assert.Equal(t, times[2], parallel.Failures[4].FirstTime)
+	assert.True(t, parallel.isValid(4, 1))
+	assert.False(t, parallel.isValid(4, 2))
+
+	expected, _ := satTwo.propagateAtTime(times[7])
+	assert.Equal(t, expected, parallel.Positions[1][7])
+}
+
+func TestFailedSamplesAreNotClustered(t *testing.T) {
+	start := createJulianDate(2025, 1, 12, 0, 0, 0)
+	times := []JulianDate{start, julianDateAddSeconds(start, 60)}
+
+	// Both fail at the second time, where their zero positions would
+	// otherwise share a cluster at the centre of the Earth
+	satellites := []Propagator{
+		&failingPropagator{linearPropagator: linearPropagator{id: "A", reference: start, position: SatPosition{X: 7000}}, cutoff: start},
+		&failingPropagator{linearPropagator: linearPropagator{id: "B", reference: start, position: SatPosition{X: -7000}}, cutoff: start},
+		&linearPropagator{id: "C", reference: start, position: SatPosition{X: 7000.5}},
+	}
+
+	config := defaultConfig()
+	ephemeris := buildSatLocations(satellites, times, config.Ephemeris, io.Discard)
+	atRiskPairs := tierOneCollisionsWithWorkerPool(len(times), len(satellites), ephemeris, config.TierOne, io.Discard)
+
+	assert.Equal(t, []SatPair{{ID1: 0, ID2: 2}}, atRiskPairs[0])
+	assert.Empty(t, atRiskPairs[1])
+}
+
+func TestClassifyPropagationError(t *testing.T) {
+	assert.Equal(t, FailureDecayed, classifyPropagationError(fmt.Errorf("propagation error: %w", errSgp4Decayed)))
+	assert.Equal(t, FailureBadElements, classifyPropagationError(errSgp4Eccentricity))
+	assert.Equal(t, FailureNumerical, classifyPropagationError(errSgp4SemiLatus))
+	assert.Equal(t, FailureDecayed, classifyPropagationError(fmt.Errorf("Satellite has Decayed")))
+	assert.Equal(t, FailureNumerical, classifyPropagationError(fmt.Errorf("error 7")))
+}
@@ -0,0 +1,131 @@
+//go:build !spacetrack
+
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// Spg4Satellite is backed by the pure Go SGP4 implementation unless the
+// program is built with the spacetrack tag, see sgp4.go
+type Spg4Satellite struct {
+	TLE1      string
+	TLE2      string
+	catalogID string
+	rec       *sgp4Record
+}
+
+func NewSgp4Satellite(tle1, tle2 string) (*Spg4Satellite, error) {
+	elements, err := sgp4ElementsFromTLE(tle1, tle2)
+	if err != nil {
+		return nil, &SatelliteInitError{ObjectID: tleCatalogNumber(tle1), Message: err.Error()}
+	}
+
+	rec, err := newSgp4Record(elements)
+	if err != nil {
+		return nil, &SatelliteInitError{ObjectID: tleCatalogNumber(tle1), Message: err.Error()}
+	}
+
+	return &Spg4Satellite{TLE1: tle1, TLE2: tle2, catalogID: tleCatalogNumber(tle1), rec: rec}, nil
+}
+
+// NewSgp4SatelliteFromOMM initialises SGP4 straight from OMM mean elements,
+// so no TLE text is involved
+func NewSgp4SatelliteFromOMM(omm *OMM) (*Spg4Satellite, error) {
+	elements, err := omm.sgp4Elements()
+	if err != nil {
+		return nil, &SatelliteInitError{ObjectID: omm.catalogID(), Message: err.Error()}
+	}
+
+	rec, err := newSgp4Record(elements)
+	if err != nil {
+		return nil, &SatelliteInitError{ObjectID: omm.catalogID(), Message: err.Error()}
+	}
+
+	return &Spg4Satellite{catalogID: omm.catalogID(), rec: rec}, nil
+}
+
+func (s *Spg4Satellite) propagateAtTime(julianDate JulianDate) (SatPosition, error) {
+	pos, _, err := s.rec.propagate(s.rec.minutesSinceEpoch(julianDate))
+	if err != nil {
+		return SatPosition{}, fmt.Errorf("propagation error: %w", err)
+	}
+
+	return SatPosition{X: pos[0], Y: pos[1], Z: pos[2]}, nil
+}
+
+func (s *Spg4Satellite) stateAtTime(julianDate JulianDate) (SatState, error) {
+	pos, vel, err := s.rec.propagate(s.rec.minutesSinceEpoch(julianDate))
+	if err != nil {
+		return SatState{}, fmt.Errorf("propagation error: %w", err)
+	}
+
+	return SatState{
+		JulianTime: julianDate,
+		Position:   SatPosition{X: pos[0], Y: pos[1], Z: pos[2]},
+		Velocity:   SatVelocity{X: vel[0], Y: vel[1], Z: vel[2]},
+	}, nil
+}
+
+func (s *Spg4Satellite) meanElementsAtTime(julianDate JulianDate) (MeanElements, error) {
+	_, _, mean, err := s.rec.propagateWithMean(s.rec.minutesSinceEpoch(julianDate))
+	if err != nil {
+		return MeanElements{}, fmt.Errorf("propagation error: %w", err)
+	}
+
+	return MeanElements{
+		SemiMajorAxis: mean.am * wgs72RadiusEarthKm,
+		Eccentricity:  mean.em,
+		Inclination:   radiansToDegrees(mean.im),
+		RAAN:          radiansToDegrees(mean.om),
+		ArgPerigee:    radiansToDegrees(mean.argp),
+		MeanAnomaly:   radiansToDegrees(mean.mm),
+	}, nil
+}
+
+// The native satellites implement DispersiblePropagator, so montecarlo can
+// run on this build
+const dispersibleBackend = true
+
+// dispersed rebuilds the satellite from its mean elements perturbed by
+// dispersion, for Monte Carlo
+func (s *Spg4Satellite) dispersed(dispersion ElementDispersion) (Propagator, error) {
+	elements := dispersion.apply(sgp4Elements{
+		EpochJD:       s.rec.jdsatepoch,
+		EpochFraction: s.rec.jdsatepochF,
+		Bstar:         s.rec.bstar,
+		Inclination:   s.rec.inclo,
+		RAAN:          s.rec.nodeo,
+		Eccentricity:  s.rec.ecco,
+		ArgPerigee:    s.rec.argpo,
+		MeanAnomaly:   s.rec.mo,
+		MeanMotion:    s.rec.noKozai,
+	})
+
+	rec, err := newSgp4Record(elements)
+	if err != nil {
+		return nil, err
+	}
+	return &Spg4Satellite{catalogID: s.catalogID, rec: rec}, nil
+}
+
+func (s *Spg4Satellite) satelliteID() string {
+	return s.catalogID
+}
+
+func (s *Spg4Satellite) epoch() JulianDate {
+	return newJulianDate(s.rec.jdsatepoch, s.rec.jdsatepochF)
+}
+
+// Angle in degrees wrapped to [0, 360)
+func radiansToDegrees(angle float64) float64 {
+	degrees := math.Mod(angle*180.0/math.Pi, 360.0)
+	if degrees < 0 {
+		degrees += 360.0
+	}
+	return degrees
+}
+
+// Nothing to release for the native backend
+func (s *Spg4Satellite) destroySat() {}
@@ -1,3 +1,5 @@
+//go:build spacetrack
+
 package main
 
 // #cgo CFLAGS: -I${SRCDIR}/sgp4/wrappers
@@ -12,41 +14,99 @@ package main
 import "C"
 import (
 	"fmt"
-	"os"
 	"strings"
+	"sync"
 	"unsafe"
 )
 
-type SatPosition struct {
-	X float64
-	Y float64
-	Z float64
-}
+// The library keeps every loaded satellite in global state keyed by satKey
+// and is not safe to call from several threads, so every call into it holds
+// this lock. Callers can then share satellites between goroutines freely.
+var libraryMu sync.Mutex
+
+// Satellites in the library cannot be rebuilt from perturbed elements, so
+// montecarlo is refused on this build before it loads anything
+const dispersibleBackend = false
 
 type Spg4Satellite struct {
-	TLE1   string
-	TLE2   string
-	satKey C.long
+	TLE1      string
+	TLE2      string
+	catalogID string
+	epochJD   JulianDate
+	satKey    C.long
 }
 
-func NewSgp4Satellite(tle1, tle2 string) Spg4Satellite {
+func NewSgp4Satellite(tle1, tle2 string) (*Spg4Satellite, error) {
+	libraryMu.Lock()
+	defer libraryMu.Unlock()
+
 	line1 := C.CString(tle1)
 	line2 := C.CString(tle2)
 	defer C.free(unsafe.Pointer(line1))
 	defer C.free(unsafe.Pointer(line2))
 
 	satKey := C.TleAddSatFrLines(line1, line2)
+	if satKey <= 0 {
+		return nil, &SatelliteInitError{ObjectID: tleCatalogNumber(tle1), Message: lastErrMsg()}
+	}
 
 	ErrCode := C.Sgp4InitSat(satKey)
 	if ErrCode != 0 {
-		fmt.Println("Error initializing SGP4")
-		exitErr()
+		err := &SatelliteInitError{ObjectID: tleCatalogNumber(tle1), Message: lastErrMsg()}
+		C.TleRemoveSat(satKey)
+		return nil, err
+	}
+
+	epochJD := JulianDate{}
+	if elements, err := sgp4ElementsFromTLE(tle1, tle2); err == nil {
+		epochJD = newJulianDate(elements.EpochJD, elements.EpochFraction)
 	}
 
-	return Spg4Satellite{TLE1: tle1, TLE2: tle2, satKey: satKey}
+	return &Spg4Satellite{TLE1: tle1, TLE2: tle2, catalogID: tleCatalogNumber(tle1), epochJD: epochJD, satKey: satKey}, nil
 }
 
-func (s *Spg4Satellite) propagateAtTime(julianDate float64) (SatPosition, error) {
+// NewSgp4SatelliteFromOMM loads OMM mean elements into the library field by
+// field, so no TLE text is involved
+func NewSgp4SatelliteFromOMM(omm *OMM) (*Spg4Satellite, error) {
+	elements, err := omm.sgp4Elements()
+	if err != nil {
+		return nil, &SatelliteInitError{ObjectID: omm.catalogID(), Message: err.Error()}
+	}
+
+	libraryMu.Lock()
+	defer libraryMu.Unlock()
+
+	epoch, _ := parseOMMEpoch(omm.Epoch)
+	epochDays := float64(epoch.YearDay()) + elements.EpochFraction
+	secClass := byte('U')
+	if omm.ClassificationType != "" {
+		secClass = omm.ClassificationType[0]
+	}
+
+	satName := C.CString(omm.ObjectName)
+	defer C.free(unsafe.Pointer(satName))
+
+	satKey := C.TleAddSatFrFieldsGP(C.int(omm.NoradCatID), C.char(secClass), satName,
+		C.int(epoch.Year()), C.double(epochDays), C.double(omm.Bstar), C.int(omm.EphemerisType), C.int(omm.ElementSetNo),
+		C.double(omm.Inclination), C.double(omm.RAAN), C.double(omm.Eccentricity), C.double(omm.ArgPericenter),
+		C.double(omm.MeanAnomaly), C.double(omm.MeanMotion), C.int(omm.RevAtEpoch))
+	if satKey <= 0 {
+		return nil, &SatelliteInitError{ObjectID: omm.catalogID(), Message: lastErrMsg()}
+	}
+
+	ErrCode := C.Sgp4InitSat(satKey)
+	if ErrCode != 0 {
+		err := &SatelliteInitError{ObjectID: omm.catalogID(), Message: lastErrMsg()}
+		C.TleRemoveSat(satKey)
+		return nil, err
+	}
+
+	return &Spg4Satellite{catalogID: omm.catalogID(), epochJD: newJulianDate(elements.EpochJD, elements.EpochFraction), satKey: satKey}, nil
+}
+
+func (s *Spg4Satellite) propagateAtTime(julianDate JulianDate) (SatPosition, error) {
+	libraryMu.Lock()
+	defer libraryMu.Unlock()
 
 	// julianDate := satellite.JDay(year, month, day, hours, minutes, seconds)
 	// utc50Date := julianDate - 2433281.5
@@ -56,13 +116,7 @@ func (s *Spg4Satellite) propagateAtTime(julianDate float64) (SatPosition, error)
 
 	ErrCode := C.Sgp4PropDs50UtcPos(s.satKey, C.double(utc50Date), &pos[0])
 	if ErrCode != 0 {
-		// Go-managed buffer
-		lastErrMsg := make([]byte, 128)
-		// Pass slice to C
-		C.GetLastErrMsg((*C.char)(unsafe.Pointer(&lastErrMsg[0])))
-		// Convert to Go string
-		errMsg := string(lastErrMsg)
-		return SatPosition{}, fmt.Errorf("propagation error: %s", strings.TrimSpace(errMsg))
+		return SatPosition{}, fmt.Errorf("propagation error: %s", lastErrMsg())
 	}
 
 	return SatPosition{
@@ -72,11 +126,92 @@ func (s *Spg4Satellite) propagateAtTime(julianDate float64) (SatPosition, error)
 	}, nil
 }
 
+func (s *Spg4Satellite) stateAtTime(julianDate JulianDate) (SatState, error) {
+	libraryMu.Lock()
+	defer libraryMu.Unlock()
+
+	var mse C.double
+	pos := make([]C.double, 3)
+	vel := make([]C.double, 3)
+	llh := make([]C.double, 3)
+	utc50Date := julianDateToUTC50(julianDate)
+
+	ErrCode := C.Sgp4PropDs50UTC(s.satKey, C.double(utc50Date), &mse, &pos[0], &vel[0], &llh[0])
+	if ErrCode != 0 {
+		return SatState{}, fmt.Errorf("propagation error: %s", lastErrMsg())
+	}
+
+	return SatState{
+		JulianTime: julianDate,
+		Position:   SatPosition{X: float64(pos[0]), Y: float64(pos[1]), Z: float64(pos[2])},
+		Velocity:   SatVelocity{X: float64(vel[0]), Y: float64(vel[1]), Z: float64(vel[2])},
+	}, nil
+}
+
+// Mean elements via Sgp4PosVelToKep, as in the runSgp4 example below
+func (s *Spg4Satellite) meanElementsAtTime(julianDate JulianDate) (MeanElements, error) {
+	libraryMu.Lock()
+	defer libraryMu.Unlock()
+
+	var mse C.double
+	var yr C.int
+	var day C.double
+	pos := make([]C.double, 3)
+	vel := make([]C.double, 3)
+	llh := make([]C.double, 3)
+	posnew := make([]C.double, 3)
+	velnew := make([]C.double, 3)
+	sgp4MeanKep := make([]C.double, 6)
+	utc50Date := julianDateToUTC50(julianDate)
+
+	ErrCode := C.Sgp4PropDs50UTC(s.satKey, C.double(utc50Date), &mse, &pos[0], &vel[0], &llh[0])
+	if ErrCode != 0 {
+		return MeanElements{}, fmt.Errorf("propagation error: %s", lastErrMsg())
+	}
+
+	C.UTCToYrDays(C.double(utc50Date), &yr, &day)
+	ErrCode = C.Sgp4PosVelToKep(yr, day, &pos[0], &vel[0], &posnew[0], &velnew[0], &sgp4MeanKep[0])
+	if ErrCode != 0 {
+		return MeanElements{}, fmt.Errorf("mean element conversion error: %s", lastErrMsg())
+	}
+
+	// Keplerian arrays are ordered a, e, incli, ma, node, omega
+	return MeanElements{
+		SemiMajorAxis: float64(sgp4MeanKep[0]),
+		Eccentricity:  float64(sgp4MeanKep[1]),
+		Inclination:   float64(sgp4MeanKep[2]),
+		MeanAnomaly:   float64(sgp4MeanKep[3]),
+		RAAN:          float64(sgp4MeanKep[4]),
+		ArgPerigee:    float64(sgp4MeanKep[5]),
+	}, nil
+}
+
+func (s *Spg4Satellite) satelliteID() string {
+	return s.catalogID
+}
+
+func (s *Spg4Satellite) epoch() JulianDate {
+	return s.epochJD
+}
+
 // Destroys the satellite from the underlying SGP4 library
 func (s *Spg4Satellite) destroySat() {
+	libraryMu.Lock()
+	defer libraryMu.Unlock()
+
 	C.Sgp4RemoveSat(s.satKey)
 }
 
+// lastErrMsg must be called with libraryMu held
+func lastErrMsg() string {
+	// Go-managed buffer
+	lastErrMsg := make([]byte, 128)
+	// Pass slice to C
+	C.GetLastErrMsg((*C.char)(unsafe.Pointer(&lastErrMsg[0])))
+	// Convert to Go string
+	return strings.TrimSpace(strings.TrimRight(string(lastErrMsg), "\x00"))
+}
+
 func allocstr(length int) string {
 	var b strings.Builder
 	for i := 0; i < length; i++ {
@@ -86,13 +221,6 @@ func allocstr(length int) string {
 	return str
 }
 
-func exitErr() {
-	lastErrMsg := C.CString(allocstr(128))
-	C.GetLastErrMsg(lastErrMsg)
-	fmt.Println(C.GoString(lastErrMsg))
-	os.Exit(0)
-}
-
 /*
 
 
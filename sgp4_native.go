@@ -0,0 +1,491 @@
+package main
+
+import (
+	"errors"
+	"math"
+)
+
+// Pure Go port of the SGP4/SDP4 propagator described in "Revisiting Spacetrack
+// Report #3" (Vallado, Crawford, Hujsak, Kelso 2006). The structure and naming
+// follow the reference implementation so the two can be compared line by line.
+// Only WGS-72 constants and the "improved" operation mode are supported, which
+// is what the python sgp4 package and space-track.org use by default.
+
+const (
+	wgs72Mu            = 398600.8 // km^3/s^2
+	wgs72RadiusEarthKm = 6378.135
+	wgs72J2            = 0.001082616
+	wgs72J3            = -0.00000253881
+	wgs72J4            = -0.00000165597
+	wgs72J3oJ2         = wgs72J3 / wgs72J2
+
+	twoPi = 2.0 * math.Pi
+	x2o3  = 2.0 / 3.0
+)
+
+// Earth radii per minute, derived from the gravity constants
+var wgs72Xke = 60.0 / math.Sqrt(wgs72RadiusEarthKm*wgs72RadiusEarthKm*wgs72RadiusEarthKm/wgs72Mu)
+
+var (
+	errSgp4MeanMotion    = errors.New("mean motion less than or equal to zero")
+	errSgp4Eccentricity  = errors.New("mean eccentricity out of range")
+	errSgp4PerturbedEcc  = errors.New("perturbed eccentricity out of range")
+	errSgp4SemiLatus     = errors.New("semi-latus rectum less than zero")
+	errSgp4Decayed       = errors.New("satellite has decayed")
+	errSgp4BadElementSet = errors.New("invalid mean elements")
+)
+
+// sgp4Elements are the mean elements as read from a TLE or OMM, already
+// converted to radians and radians per minute.
+type sgp4Elements struct {
+	// Julian date of the epoch split into the midnight day and the day fraction
+	EpochJD       float64
+	EpochFraction float64
+
+	Bstar        float64
+	Inclination  float64
+	RAAN         float64
+	Eccentricity float64
+	ArgPerigee   float64
+	MeanAnomaly  float64
+	MeanMotion   float64 // Kozai mean motion in rad/min
+}
+
+// sgp4Record holds everything sgp4init derives from the mean elements. After
+// initialisation it is never written to, so a single record can be propagated
+// from many goroutines.
+type sgp4Record struct {
+	jdsatepoch  float64
+	jdsatepochF float64
+
+	bstar, inclo, nodeo, ecco, argpo, mo, noKozai, noUnkozai float64
+
+	isimp  int
+	method byte
+
+	aycof, con41, cc1, cc4, cc5, d2, d3, d4, delmo, eta, argpdot, omgcof float64
+	sinmao, t2cof, t3cof, t4cof, t5cof, x1mth2, x7thm1, mdot, nodedot    float64
+	xlcof, xmcof, nodecf                                                 float64
+
+	// Deep space
+	irez                                                       int
+	d2201, d2211, d3210, d3222, d4410, d4422, d5220, d5232     float64
+	d5421, d5433, dedt, del1, del2, del3, didt, dmdt, dnodt    float64
+	domdt, e3, ee2, peo, pgho, pho, pinco, plo, se2, se3, sgh2 float64
+	sgh3, sgh4, sh2, sh3, si2, si3, sl2, sl3, sl4, gsto, xfact float64
+	xgh2, xgh3, xgh4, xh2, xh3, xi2, xi3, xl2, xl3, xl4        float64
+	xlamo, zmol, zmos                                          float64
+}
+
+func newSgp4Record(el sgp4Elements) (*sgp4Record, error) {
+	if el.MeanMotion <= 0 || el.Eccentricity < 0 || el.Eccentricity >= 1 {
+		return nil, errSgp4BadElementSet
+	}
+
+	rec := &sgp4Record{
+		jdsatepoch:  el.EpochJD,
+		jdsatepochF: el.EpochFraction,
+		bstar:       el.Bstar,
+		inclo:       el.Inclination,
+		nodeo:       el.RAAN,
+		ecco:        el.Eccentricity,
+		argpo:       el.ArgPerigee,
+		mo:          el.MeanAnomaly,
+		noKozai:     el.MeanMotion,
+	}
+
+	if err := rec.init(); err != nil {
+		return nil, err
+	}
+	return rec, nil
+}
+
+// init corresponds to sgp4init in the reference implementation
+func (rec *sgp4Record) init() error {
+	const temp4 = 1.5e-12
+
+	radiusearthkm := wgs72RadiusEarthKm
+	j2, j4, j3oj2 := wgs72J2, wgs72J4, wgs72J3oJ2
+
+	epoch := rec.jdsatepoch + rec.jdsatepochF - 2433281.5
+
+	ss := 78.0/radiusearthkm + 1.0
+	qzms2ttemp := (120.0 - 78.0) / radiusearthkm
+	qzms2t := qzms2ttemp * qzms2ttemp * qzms2ttemp * qzms2ttemp
+
+	in := initl(rec.ecco, epoch, rec.inclo, rec.noKozai)
+	rec.noUnkozai = in.noUnkozai
+	rec.con41 = in.con41
+	rec.gsto = in.gsto
+
+	if in.omeosq >= 0.0 || rec.noUnkozai >= 0.0 {
+		rec.isimp = 0
+		if in.rp < (220.0/radiusearthkm + 1.0) {
+			rec.isimp = 1
+		}
+		sfour := ss
+		qzms24 := qzms2t
+		perige := (in.rp - 1.0) * radiusearthkm
+
+		// For perigees below 156 km, s and qoms2t are altered
+		if perige < 156.0 {
+			sfour = perige - 78.0
+			if perige < 98.0 {
+				sfour = 20.0
+			}
+			qzms24 = math.Pow((120.0-sfour)/radiusearthkm, 4.0)
+			sfour = sfour/radiusearthkm + 1.0
+		}
+		pinvsq := 1.0 / in.posq
+
+		tsi := 1.0 / (in.ao - sfour)
+		rec.eta = in.ao * rec.ecco * tsi
+		etasq := rec.eta * rec.eta
+		eeta := rec.ecco * rec.eta
+		psisq := math.Abs(1.0 - etasq)
+		coef := qzms24 * math.Pow(tsi, 4.0)
+		coef1 := coef / math.Pow(psisq, 3.5)
+		cc2 := coef1 * rec.noUnkozai * (in.ao*(1.0+1.5*etasq+eeta*(4.0+etasq)) +
+			0.375*j2*tsi/psisq*rec.con41*(8.0+3.0*etasq*(8.0+etasq)))
+		rec.cc1 = rec.bstar * cc2
+		cc3 := 0.0
+		if rec.ecco > 1.0e-4 {
+			cc3 = -2.0 * coef * tsi * j3oj2 * rec.noUnkozai * in.sinio / rec.ecco
+		}
+		rec.x1mth2 = 1.0 - in.cosio2
+		rec.cc4 = 2.0 * rec.noUnkozai * coef1 * in.ao * in.omeosq *
+			(rec.eta*(2.0+0.5*etasq) + rec.ecco*(0.5+2.0*etasq) -
+				j2*tsi/(in.ao*psisq)*
+					(-3.0*rec.con41*(1.0-2.0*eeta+etasq*(1.5-0.5*eeta))+
+						0.75*rec.x1mth2*(2.0*etasq-eeta*(1.0+etasq))*math.Cos(2.0*rec.argpo)))
+		rec.cc5 = 2.0 * coef1 * in.ao * in.omeosq * (1.0 + 2.75*(etasq+eeta) + eeta*etasq)
+		cosio4 := in.cosio2 * in.cosio2
+		temp1 := 1.5 * j2 * pinvsq * rec.noUnkozai
+		temp2 := 0.5 * temp1 * j2 * pinvsq
+		temp3 := -0.46875 * j4 * pinvsq * pinvsq * rec.noUnkozai
+		rec.mdot = rec.noUnkozai + 0.5*temp1*in.rteosq*rec.con41 +
+			0.0625*temp2*in.rteosq*(13.0-78.0*in.cosio2+137.0*cosio4)
+		rec.argpdot = -0.5*temp1*in.con42 + 0.0625*temp2*(7.0-114.0*in.cosio2+395.0*cosio4) +
+			temp3*(3.0-36.0*in.cosio2+49.0*cosio4)
+		xhdot1 := -temp1 * in.cosio
+		rec.nodedot = xhdot1 + (0.5*temp2*(4.0-19.0*in.cosio2)+2.0*temp3*(3.0-7.0*in.cosio2))*in.cosio
+		xpidot := rec.argpdot + rec.nodedot
+		rec.omgcof = rec.bstar * cc3 * math.Cos(rec.argpo)
+		rec.xmcof = 0.0
+		if rec.ecco > 1.0e-4 {
+			rec.xmcof = -x2o3 * coef * rec.bstar / eeta
+		}
+		rec.nodecf = 3.5 * in.omeosq * xhdot1 * rec.cc1
+		rec.t2cof = 1.5 * rec.cc1
+		// Avoid a divide by zero for inclination = 180 deg
+		if math.Abs(in.cosio+1.0) > 1.5e-12 {
+			rec.xlcof = -0.25 * j3oj2 * in.sinio * (3.0 + 5.0*in.cosio) / (1.0 + in.cosio)
+		} else {
+			rec.xlcof = -0.25 * j3oj2 * in.sinio * (3.0 + 5.0*in.cosio) / temp4
+		}
+		rec.aycof = -0.5 * j3oj2 * in.sinio
+		delmotemp := 1.0 + rec.eta*math.Cos(rec.mo)
+		rec.delmo = delmotemp * delmotemp * delmotemp
+		rec.sinmao = math.Sin(rec.mo)
+		rec.x7thm1 = 7.0*in.cosio2 - 1.0
+
+		// Deep space initialisation for periods of 225 minutes or more
+		if 2*math.Pi/rec.noUnkozai >= 225.0 {
+			rec.method = 'd'
+			rec.isimp = 1
+			tc := 0.0
+			inclm := rec.inclo
+
+			ds := rec.dscom(epoch, rec.ecco, rec.argpo, tc, rec.inclo, rec.nodeo, rec.noUnkozai)
+
+			// dpper is a no-op during initialisation, so it is not called here
+			rec.dsinit(ds, tc, xpidot, inclm)
+		} else {
+			rec.method = 'n'
+		}
+
+		if rec.isimp != 1 {
+			cc1sq := rec.cc1 * rec.cc1
+			rec.d2 = 4.0 * in.ao * tsi * cc1sq
+			temp := rec.d2 * tsi * rec.cc1 / 3.0
+			rec.d3 = (17.0*in.ao + sfour) * temp
+			rec.d4 = 0.5 * temp * in.ao * tsi * (221.0*in.ao + 31.0*sfour) * rec.cc1
+			rec.t3cof = rec.d2 + 2.0*cc1sq
+			rec.t4cof = 0.25 * (3.0*rec.d3 + rec.cc1*(12.0*rec.d2+10.0*cc1sq))
+			rec.t5cof = 0.2 * (3.0*rec.d4 + 12.0*rec.cc1*rec.d3 + 6.0*rec.d2*rec.d2 +
+				15.0*cc1sq*(2.0*rec.d2+cc1sq))
+		}
+	}
+
+	// Propagate to the epoch so bad element sets are rejected up front
+	_, _, err := rec.propagate(0.0)
+	return err
+}
+
+type initlResult struct {
+	ao, con41, con42, cosio, cosio2, omeosq, posq, rp, rteosq, sinio, gsto, noUnkozai float64
+}
+
+func initl(ecco, epoch, inclo, noKozai float64) initlResult {
+	var out initlResult
+
+	eccsq := ecco * ecco
+	out.omeosq = 1.0 - eccsq
+	out.rteosq = math.Sqrt(out.omeosq)
+	out.cosio = math.Cos(inclo)
+	out.cosio2 = out.cosio * out.cosio
+
+	// Un-kozai the mean motion
+	ak := math.Pow(wgs72Xke/noKozai, x2o3)
+	d1 := 0.75 * wgs72J2 * (3.0*out.cosio2 - 1.0) / (out.rteosq * out.omeosq)
+	del := d1 / (ak * ak)
+	adel := ak * (1.0 - del*del - del*(1.0/3.0+134.0*del*del/81.0))
+	del = d1 / (adel * adel)
+	out.noUnkozai = noKozai / (1.0 + del)
+
+	out.ao = math.Pow(wgs72Xke/out.noUnkozai, x2o3)
+	out.sinio = math.Sin(inclo)
+	po := out.ao * out.omeosq
+	out.con42 = 1.0 - 5.0*out.cosio2
+	out.con41 = -out.con42 - out.cosio2 - out.cosio2
+	out.posq = po * po
+	out.rp = out.ao * (1.0 - ecco)
+
+	out.gsto = gstime(epoch + 2433281.5)
+	return out
+}
+
+// gstime returns the Greenwich sidereal time in radians for a UT1 Julian date
+func gstime(jdut1 float64) float64 {
+	tut1 := (jdut1 - 2451545.0) / 36525.0
+	temp := -6.2e-6*tut1*tut1*tut1 + 0.093104*tut1*tut1 +
+		(876600.0*3600+8640184.812866)*tut1 + 67310.54841 // sec
+	temp = math.Mod(temp*(math.Pi/180.0)/240.0, twoPi) // 360/86400 = 1/240, to deg, to rad
+
+	if temp < 0.0 {
+		temp += twoPi
+	}
+	return temp
+}
+
+// sgp4MeanState are the mean elements SGP4 computes on the way to the
+// osculating state, with am in earth radii and nm in rad/min
+type sgp4MeanState struct {
+	am, em, im, om, argp, mm, nm float64
+}
+
+// propagate runs SGP4 for tsince minutes from epoch and returns the TEME
+// position in km and velocity in km/s
+func (rec *sgp4Record) propagate(tsince float64) (r, v [3]float64, err error) {
+	r, v, _, err = rec.propagateWithMean(tsince)
+	return r, v, err
+}
+
+func (rec *sgp4Record) propagateWithMean(tsince float64) (r, v [3]float64, mean sgp4MeanState, err error) {
+	const temp4 = 1.5e-12
+
+	j2, j3oj2 := wgs72J2, wgs72J3oJ2
+	xke := wgs72Xke
+	vkmpersec := wgs72RadiusEarthKm * xke / 60.0
+
+	t := tsince
+
+	// Update for secular gravity and atmospheric drag
+	xmdf := rec.mo + rec.mdot*t
+	argpdf := rec.argpo + rec.argpdot*t
+	nodedf := rec.nodeo + rec.nodedot*t
+	argpm := argpdf
+	mm := xmdf
+	t2 := t * t
+	nodem := nodedf + rec.nodecf*t2
+	tempa := 1.0 - rec.cc1*t
+	tempe := rec.bstar * rec.cc4 * t
+	templ := rec.t2cof * t2
+
+	if rec.isimp != 1 {
+		delomg := rec.omgcof * t
+		delmtemp := 1.0 + rec.eta*math.Cos(xmdf)
+		delm := rec.xmcof * (delmtemp*delmtemp*delmtemp - rec.delmo)
+		temp := delomg + delm
+		mm = xmdf + temp
+		argpm = argpdf - temp
+		t3 := t2 * t
+		t4 := t3 * t
+		tempa = tempa - rec.d2*t2 - rec.d3*t3 - rec.d4*t4
+		tempe = tempe + rec.bstar*rec.cc5*(math.Sin(mm)-rec.sinmao)
+		templ = templ + rec.t3cof*t3 + t4*(rec.t4cof+t*rec.t5cof)
+	}
+
+	nm := rec.noUnkozai
+	em := rec.ecco
+	inclm := rec.inclo
+	if rec.method == 'd' {
+		em, argpm, inclm, mm, nodem, nm = rec.dspace(t, em, argpm, inclm, mm, nodem)
+	}
+
+	if nm <= 0.0 {
+		return r, v, mean, errSgp4MeanMotion
+	}
+
+	am := math.Pow(xke/nm, x2o3) * tempa * tempa
+	nm = xke / math.Pow(am, 1.5)
+	em = em - tempe
+
+	if em >= 1.0 || em < -0.001 {
+		return r, v, mean, errSgp4Eccentricity
+	}
+	// Avoid a divide by zero
+	if em < 1.0e-6 {
+		em = 1.0e-6
+	}
+	mm = mm + rec.noUnkozai*templ
+	xlm := mm + argpm + nodem
+
+	nodem = math.Mod(nodem, twoPi)
+	argpm = math.Mod(argpm, twoPi)
+	xlm = math.Mod(xlm, twoPi)
+	mm = math.Mod(xlm-argpm-nodem, twoPi)
+
+	mean = sgp4MeanState{am: am, em: em, im: inclm, om: nodem, argp: argpm, mm: mm, nm: nm}
+
+	sinim := math.Sin(inclm)
+	cosim := math.Cos(inclm)
+
+	// Add lunar-solar periodics
+	ep := em
+	xincp := inclm
+	argpp := argpm
+	nodep := nodem
+	mp := mm
+	sinip := sinim
+	cosip := cosim
+	aycof := rec.aycof
+	xlcof := rec.xlcof
+	con41 := rec.con41
+	x1mth2 := rec.x1mth2
+	x7thm1 := rec.x7thm1
+
+	if rec.method == 'd' {
+		ep, xincp, nodep, argpp, mp = rec.dpper(t, ep, xincp, nodep, argpp, mp)
+		if xincp < 0.0 {
+			xincp = -xincp
+			nodep = nodep + math.Pi
+			argpp = argpp - math.Pi
+		}
+		if ep < 0.0 || ep > 1.0 {
+			return r, v, mean, errSgp4PerturbedEcc
+		}
+
+		// Long period periodics
+		sinip = math.Sin(xincp)
+		cosip = math.Cos(xincp)
+		aycof = -0.5 * j3oj2 * sinip
+		if math.Abs(cosip+1.0) > 1.5e-12 {
+			xlcof = -0.25 * j3oj2 * sinip * (3.0 + 5.0*cosip) / (1.0 + cosip)
+		} else {
+			xlcof = -0.25 * j3oj2 * sinip * (3.0 + 5.0*cosip) / temp4
+		}
+	}
+
+	axnl := ep * math.Cos(argpp)
+	temp := 1.0 / (am * (1.0 - ep*ep))
+	aynl := ep*math.Sin(argpp) + temp*aycof
+	xl := mp + argpp + nodep + temp*xlcof*axnl
+
+	// Solve Kepler's equation
+	u := math.Mod(xl-nodep, twoPi)
+	eo1 := u
+	tem5 := 9999.9
+	var sineo1, coseo1 float64
+	for ktr := 1; math.Abs(tem5) >= 1.0e-12 && ktr <= 10; ktr++ {
+		sineo1 = math.Sin(eo1)
+		coseo1 = math.Cos(eo1)
+		tem5 = 1.0 - coseo1*axnl - sineo1*aynl
+		tem5 = (u - aynl*coseo1 + axnl*sineo1 - eo1) / tem5
+		if math.Abs(tem5) >= 0.95 {
+			if tem5 > 0.0 {
+				tem5 = 0.95
+			} else {
+				tem5 = -0.95
+			}
+		}
+		eo1 = eo1 + tem5
+	}
+
+	// Short period preliminary quantities
+	ecose := axnl*coseo1 + aynl*sineo1
+	esine := axnl*sineo1 - aynl*coseo1
+	el2 := axnl*axnl + aynl*aynl
+	pl := am * (1.0 - el2)
+	if pl < 0.0 {
+		return r, v, mean, errSgp4SemiLatus
+	}
+
+	rl := am * (1.0 - ecose)
+	rdotl := math.Sqrt(am) * esine / rl
+	rvdotl := math.Sqrt(pl) / rl
+	betal := math.Sqrt(1.0 - el2)
+	temp = esine / (1.0 + betal)
+	sinu := am / rl * (sineo1 - aynl - axnl*temp)
+	cosu := am / rl * (coseo1 - axnl + aynl*temp)
+	su := math.Atan2(sinu, cosu)
+	sin2u := (cosu + cosu) * sinu
+	cos2u := 1.0 - 2.0*sinu*sinu
+	temp = 1.0 / pl
+	temp1 := 0.5 * j2 * temp
+	temp2 := temp1 * temp
+
+	// Update for short period periodics
+	if rec.method == 'd' {
+		cosisq := cosip * cosip
+		con41 = 3.0*cosisq - 1.0
+		x1mth2 = 1.0 - cosisq
+		x7thm1 = 7.0*cosisq - 1.0
+	}
+	mrt := rl*(1.0-1.5*temp2*betal*con41) + 0.5*temp1*x1mth2*cos2u
+	su = su - 0.25*temp2*x7thm1*sin2u
+	xnode := nodep + 1.5*temp2*cosip*sin2u
+	xinc := xincp + 1.5*temp2*cosip*sinip*cos2u
+	mvt := rdotl - nm*temp1*x1mth2*sin2u/xke
+	rvdot := rvdotl + nm*temp1*(x1mth2*cos2u+1.5*con41)/xke
+
+	// Orientation vectors
+	sinsu := math.Sin(su)
+	cossu := math.Cos(su)
+	snod := math.Sin(xnode)
+	cnod := math.Cos(xnode)
+	sini := math.Sin(xinc)
+	cosi := math.Cos(xinc)
+	xmx := -snod * cosi
+	xmy := cnod * cosi
+	ux := xmx*sinsu + cnod*cossu
+	uy := xmy*sinsu + snod*cossu
+	uz := sini * sinsu
+	vx := xmx*cossu - cnod*sinsu
+	vy := xmy*cossu - snod*sinsu
+	vz := sini * cossu
+
+	// Position and velocity (in km and km/sec)
+	r[0] = (mrt * ux) * wgs72RadiusEarthKm
+	r[1] = (mrt * uy) * wgs72RadiusEarthKm
+	r[2] = (mrt * uz) * wgs72RadiusEarthKm
+	v[0] = (mvt*ux + rvdot*vx) * vkmpersec
+	v[1] = (mvt*uy + rvdot*vy) * vkmpersec
+	v[2] = (mvt*uz + rvdot*vz) * vkmpersec
+
+	if mrt < 1.0 {
+		return r, v, mean, errSgp4Decayed
+	}
+
+	return r, v, mean, nil
+}
+
+// minutesSinceEpoch keeps the whole days and the day fractions apart so the
+// subtraction does not lose precision near JD 2.46e6. SGP4 counts uniform
+// 86,400 s UTC days like the reference code and the cgo backend, so leap
+// seconds since the epoch are not added. The epoch day fraction is already
+// uniform, as written in the TLE.
+func (rec *sgp4Record) minutesSinceEpoch(julianDate JulianDate) float64 {
+	epoch := JulianDate{Day: rec.jdsatepoch, Fraction: rec.jdsatepochF}
+	return daySecondsBetween(epoch, uniformUTC(julianDate)) / 60.0
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Verification cases from "Revisiting Spacetrack Report #3" (tcppver.out)
+
+func TestNativeSgp4NearEarthVerification(t *testing.T) {
+	elements, err := sgp4ElementsFromTLE(
+		"1 00005U 58002B   00179.78495062  .00000023  00000-0  28098-4 0  4753",
+		"2 00005  34.2682 348.7242 1859667 331.7664  19.3264 10.82419157413667",
+	)
+	assert.NoError(t, err)
+
+	rec, err := newSgp4Record(elements)
+	assert.NoError(t, err)
+
+	r, v, err := rec.propagate(0)
+	assert.NoError(t, err)
+	assert.InDeltaSlice(t, []float64{7022.46529266, -1400.08296755, 0.03995155}, r[:], 1e-6)
+	assert.InDeltaSlice(t, []float64{1.893841015, 6.405893759, 4.534807250}, v[:], 1e-8)
+
+	r, _, err = rec.propagate(360)
+	assert.NoError(t, err)
+	assert.InDeltaSlice(t, []float64{-7154.03120202, -3783.17682504, -3536.19412294}, r[:], 1e-6)
+}
+
+func TestNativeSgp4DeepSpaceVerification(t *testing.T) {
+	// Molniya orbit, exercises the 12 hour resonance initialisation
+	elements, err := sgp4ElementsFromTLE(
+		"1 08195U 75081A   06176.33215444  .00000099  00000-0  11873-3 0   813",
+		"2 08195  64.1586 279.0717 6877146 264.7651  20.2257  2.00491383225656",
+	)
+	assert.NoError(t, err)
+
+	rec, err := newSgp4Record(elements)
+	assert.NoError(t, err)
+	assert.Equal(t, byte('d'), rec.method)
+	assert.Equal(t, 2, rec.irez)
+
+	r, _, err := rec.propagate(0)
+	assert.NoError(t, err)
+	assert.InDeltaSlice(t, []float64{2349.89483350, -14785.93811562, 0.02119378}, r[:], 1e-6)
+}
+
+func TestNativeSgp4CountsUniformDaysAcrossLeapSecond(t *testing.T) {
+	// Epoch at noon on 2016-12-31, the day ending in a leap second
+	elements, err := sgp4ElementsFromTLE(
+		"1 00005U 58002B   16366.50000000  .00000023  00000-0  28098-4 0  4752",
+		"2 00005  34.2682 348.7242 1859667 331.7664  19.3264 10.82419157413667",
+	)
+	assert.NoError(t, err)
+	rec, err := newSgp4Record(elements)
+	assert.NoError(t, err)
+
+	// Reference SGP4 counts 720 minutes to the next midnight and 23:59:60
+	// as that midnight, without the leap second
+	for _, c := range []struct {
+		iso     string
+		minutes float64
+	}{
+		{"2016-12-31T18:00:00Z", 360},
+		{"2016-12-31T23:59:60Z", 720},
+		{"2017-01-01T00:00:00Z", 720},
+		{"2017-01-01T12:00:00Z", 1440},
+		{"2017-07-01T12:00:00Z", 182 * 1440},
+	} {
+		julianDate, err := isoToJulianDate(c.iso)
+		assert.NoError(t, err)
+		assert.InDelta(t, c.minutes, rec.minutesSinceEpoch(julianDate), 1e-7, c.iso)
+	}
+
+	// Propagating through the leap second, the position at 12:00 the next
+	// day is the one a uniform day after the epoch
+	after, _ := isoToJulianDate("2017-01-01T12:00:00Z")
+	r, _, err := rec.propagate(rec.minutesSinceEpoch(after))
+	assert.NoError(t, err)
+	expected, _, err := rec.propagate(1440)
+	assert.NoError(t, err)
+	assert.InDeltaSlice(t, expected[:], r[:], 1e-9)
+}
@@ -1,83 +1,259 @@
 package main
 
 import (
-	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
+	"math/rand"
 	"os"
+	"sort"
+	"strings"
 	"time"
 )
 
-type SatelliteApiData struct {
-	ObjectID string `json:"OBJECT_ID"`
-	TLE_1    string `json:"TLE_LINE1"`
-	TLE_2    string `json:"TLE_LINE2"`
+func main() {
+	if err := run(os.Args[1:], os.Stdout, os.Stderr); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return
+		}
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
 }
 
-// const INTERVALS = 20
-const INTERVALS = 360
-const TIME_STEP_MINUTES = 4
+// ScreeningWindow is the span of time sampled by the screening
+type ScreeningWindow struct {
+	Start    JulianDate
+	Duration time.Duration
+	Step     time.Duration
+}
 
-var START_JULIAN_DATE = createJulianDate(2025, 1, 12, 0, 0, 0)
+// Julian dates of every sample in the window, starting at Start
+func (w ScreeningWindow) julianTimes() []JulianDate {
+	intervals := int(w.Duration / w.Step)
 
-func main() {
+	times := []JulianDate{}
+	for i := 0; i < intervals; i++ {
+		seconds := float64(i) * w.Step.Seconds()
+		times = append(times, julianDateAddSeconds(w.Start, seconds))
+	}
+	return times
+}
 
-	startTime := time.Now()
+// LoadedCatalog holds the records that initialised, with satellites in the
+// same order as records so pair IDs index both
+type LoadedCatalog struct {
+	records    []SatelliteApiData
+	satellites []Propagator
+	rejected   []RejectedSatellite
+	excluded   int
+}
 
-	satellitesData, err := loadSatellitesData()
+func loadSatellites(path string, filter CatalogFilter, log io.Writer) (*LoadedCatalog, error) {
+	satellitesData, rejected, err := loadCatalogFile(path)
 	if err != nil {
-		fmt.Println("Error loading satellites data:", err)
-		return
+		return nil, fmt.Errorf("error loading satellites data: %w", err)
 	}
 
-	totalSatellites := len(satellitesData)
+	// Objects that re-entered before the screening window cannot collide,
+	// and the config may narrow the catalog by type, country or size
+	satellitesData, excluded := filterCatalog(satellitesData, filter)
 
-	spg4Satellites := make([]Spg4Satellite, totalSatellites)
-	for i, satApiData := range satellitesData {
-		spg4Satellites[i] = NewSgp4Satellite(satApiData.TLE_1, satApiData.TLE_2)
+	// Bad element sets are skipped and reported at the end of the run
+	catalog := &LoadedCatalog{rejected: rejected, excluded: excluded}
+	for _, satApiData := range satellitesData {
+		satellite, err := newSatelliteFromRecord(satApiData)
+		if err != nil {
+			catalog.rejected = append(catalog.rejected, RejectedSatellite{ObjectID: satApiData.ObjectID, Err: err})
+			continue
+		}
+		catalog.satellites = append(catalog.satellites, satellite)
+		catalog.records = append(catalog.records, satApiData)
 	}
 
-	times := []float64{}
-	for i := 0; i < INTERVALS; i++ {
-		seconds := float64(i) * 60.0 * TIME_STEP_MINUTES
-		times = append(times, julianDateAddSeconds(START_JULIAN_DATE, seconds))
+	if len(catalog.satellites) == 0 {
+		printRejectionReport(log, catalog.rejected)
+		return nil, fmt.Errorf("no satellites could be loaded from %s", path)
 	}
+	return catalog, nil
+}
 
-	fmt.Println("Computing satellite locations")
+// find looks a satellite up by NORAD catalog number, international
+// designator or name
+func (c *LoadedCatalog) find(id string) (int, error) {
+	for i, record := range c.records {
+		if c.satellites[i].satelliteID() == id || record.ObjectID == id || record.ObjectName == id {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("satellite %s not found in catalog", id)
+}
+
+// sample picks n satellites at random, keeping catalog order
+func (c *LoadedCatalog) sample(n int, seed int64) *LoadedCatalog {
+	if n >= len(c.satellites) {
+		return c
+	}
+
+	indexes := rand.New(rand.NewSource(seed)).Perm(len(c.satellites))[:n]
+	sort.Ints(indexes)
+
+	sampled := &LoadedCatalog{rejected: c.rejected, excluded: c.excluded}
+	for _, i := range indexes {
+		sampled.records = append(sampled.records, c.records[i])
+		sampled.satellites = append(sampled.satellites, c.satellites[i])
+	}
+	return sampled
+}
+
+// screen writes the closest pairs to out, and progress, timings and the
+// rejected satellites to log
+func screen(catalog *LoadedCatalog, config Config, out, log io.Writer) {
+	startTime := time.Now()
+
+	if catalog.excluded > 0 {
+		fmt.Fprintln(log, "Excluded", catalog.excluded, "objects by the catalog filter")
+	}
+
+	times := config.window().julianTimes()
+	totalSatellites := len(catalog.satellites)
+
+	fmt.Fprintln(log, "Computing satellite locations")
 	currentTime := time.Now()
-	satLocations := buildSatLocations(spg4Satellites, times)
-	fmt.Println("Time to precompute satellite locations:", time.Since(currentTime).Seconds())
+	ephemeris := buildSatLocations(catalog.satellites, times, config.Ephemeris, log)
+	fmt.Fprintln(log, "Time to precompute satellite locations:", time.Since(currentTime).Seconds())
+	printPropagationFailures(log, catalog, ephemeris.Failures, len(times))
+
+	// The apogee/perigee filter runs first, so satellites whose altitude
+	// bands are apart never meet in tier one
+	var counts FilterCounts
+	if config.Prefilter.Enabled {
+		ephemeris.Bands = altitudeBands(ephemeris, config.TierOne.MaxDistance+config.Prefilter.Pad)
+		counts.Removed[FilterApsis] = disjointBandPairs(ephemeris.Bands)
+	}
+
+	currentTime = time.Now()
+	var results [][]SatPair
+	if config.TierOne.Swept {
+		results = sweptCollisionsWithWorkerPool(len(times), totalSatellites, ephemeris, times, config.TierOne)
+	} else {
+		results = tierOneCollisionsWithWorkerPool(len(times), totalSatellites, ephemeris, config.TierOne, log)
+	}
+	fmt.Fprintln(log, "Time to build clusters:", time.Since(currentTime).Seconds())
+
+	var windows [][]SearchWindow
+	if config.Prefilter.Enabled {
+		currentTime = time.Now()
+		var candidateCounts FilterCounts
+		results, windows, candidateCounts = orbitFiltersWithWorkerPool(results, times, catalog.satellites, config)
+		counts.add(candidateCounts)
+		counts.print(log)
+		fmt.Fprintln(log, "Time to run orbit filters:", time.Since(currentTime).Seconds())
+	}
 
 	currentTime = time.Now()
-	results := tierOneCollisionsWithWorkerPool(len(times), totalSatellites, satLocations)
-	fmt.Println(len(results))
-	fmt.Println("Time to build clusters:", time.Since(currentTime).Seconds())
+	events := tierTwoCollisionsWithWorkerPool(results, windows, times, catalog.satellites, config.TierTwo)
+	fmt.Fprintln(log, "Time to process collisions tier two:", time.Since(currentTime).Seconds())
+	printTierTwoFailures(log, catalog, events.Failures)
 
 	currentTime = time.Now()
-	// minDistancePairs := processCollisionsTierTwo(results, times, spg4Satellites)
-	minDistancePairs := tierTwoCollisionsWithWorkerPool(results, times, spg4Satellites)
-	fmt.Println("Time to process collisions tier two:", time.Since(currentTime).Seconds())
+	if failed, err := events.attachPc(config.Pc, config.TierTwo.Workers); failed > 0 {
+		fmt.Fprintln(log, failed, "events have no collision probability, ranked last:", err)
+	}
+	fmt.Fprintln(log, "Time to compute collision probabilities:", time.Since(currentTime).Seconds())
 
-	// print first 100 results
-	for _, pair := range minDistancePairs.getTopPairs(100) {
-		oneId := satellitesData[pair.Sat1ID].ObjectID
-		twoId := satellitesData[pair.Sat2ID].ObjectID
-		fmt.Println(oneId, twoId, pair.JulianTime, pair.Distance)
+	reported := events.events()
+	if from, to, ok := config.reportRange(); ok {
+		reported = events.eventsBetween(from, to)
+	}
+	topPairs := closestEvents(reported, config.Output.Count)
+	if config.Output.RankBy == RankByPc {
+		topPairs = likeliestEvents(reported, config.Output.Count)
+	}
+	labelPairs(topPairs, catalog.records)
+	for _, pair := range topPairs {
+		oneId := catalog.records[pair.Sat1ID].ObjectID
+		twoId := catalog.records[pair.Sat2ID].ObjectID
+		fmt.Fprintln(out, pair.label(), oneId, pair.Sat1CatalogID, twoId, pair.Sat2CatalogID, formatJulianDate(pair.JulianTime), pair.Distance,
+			pair.Miss.Radial, pair.Miss.InTrack, pair.Miss.CrossTrack, pair.RelativeSpeed, pair.ApproachAngle, pair.Pc)
 	}
 
-	fmt.Println("Total time:", time.Since(startTime).Seconds())
+	printRejectionReport(log, catalog.rejected)
+
+	fmt.Fprintln(log, "Total time:", time.Since(startTime).Seconds())
 }
 
-func loadSatellitesData() ([]SatelliteApiData, error) {
-	file, err := os.Open("satellites-api.json")
-	if err != nil {
-		return []SatelliteApiData{}, err
+type RejectedSatellite struct {
+	ObjectID string
+	Err      error
+}
+
+func printRejectionReport(out io.Writer, rejected []RejectedSatellite) {
+	if len(rejected) == 0 {
+		return
 	}
-	defer file.Close()
 
-	var satellitesData []SatelliteApiData
-	if err := json.NewDecoder(file).Decode(&satellitesData); err != nil {
-		return []SatelliteApiData{}, err
+	fmt.Fprintln(out, "Rejected", len(rejected), "satellites:")
+	for _, r := range rejected {
+		fmt.Fprintln(out, r.ObjectID, r.Err)
 	}
+}
 
-	return satellitesData, nil
+// Lists the satellites that failed to propagate at some of the samples, with
+// the reasons and the first failure
+func printPropagationFailures(out io.Writer, catalog *LoadedCatalog, failures []PropagationFailures, samples int) {
+	failed := 0
+	for _, f := range failures {
+		if f.total() > 0 {
+			failed++
+		}
+	}
+	if failed == 0 {
+		return
+	}
+
+	fmt.Fprintln(out, failed, "satellites failed to propagate:")
+	for i, f := range failures {
+		if f.total() == 0 {
+			continue
+		}
+
+		counts := []string{}
+		for reason := FailureReason(0); reason < numFailureReasons; reason++ {
+			if f.Counts[reason] > 0 {
+				counts = append(counts, fmt.Sprint(reason, " ", f.Counts[reason]))
+			}
+		}
+		fmt.Fprintf(out, "%s %s %s: %d of %d samples (%s), first at %s: %v\n",
+			catalog.records[i].ObjectID, catalog.satellites[i].satelliteID(), f.reason(),
+			f.total(), samples, strings.Join(counts, ", "), f.FirstTime, f.FirstError)
+	}
+}
+
+// Lists the pairs whose tier two search had samples that failed to
+// propagate. Closest approaches next to those samples can be missing.
+func printTierTwoFailures(out io.Writer, catalog *LoadedCatalog, failures map[SatPair]PropagationFailures) {
+	if len(failures) == 0 {
+		return
+	}
+
+	pairs := make([]SatPair, 0, len(failures))
+	for pair := range failures {
+		pairs = append(pairs, pair)
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].ID1 != pairs[j].ID1 {
+			return pairs[i].ID1 < pairs[j].ID1
+		}
+		return pairs[i].ID2 < pairs[j].ID2
+	})
+
+	fmt.Fprintln(out, len(pairs), "pairs failed to propagate in tier two:")
+	for _, pair := range pairs {
+		f := failures[pair]
+		fmt.Fprintf(out, "%s %s %s: %d samples, first at %s: %v\n",
+			catalog.satellites[pair.ID1].satelliteID(), catalog.satellites[pair.ID2].satelliteID(), f.reason(),
+			f.total(), f.FirstTime, f.FirstError)
+	}
 }
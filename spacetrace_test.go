@@ -1,6 +1,7 @@
 package main
 
 import (
+	"encoding/json"
 	"math"
 	"testing"
 
@@ -17,16 +18,20 @@ const SatTwoLineTwo = "2 56700  43.0052  50.6716 0001256 262.8432  97.2268 15.02
 const SatThreeLineOne = "1 58247U 23171T   25011.52048310  .00003171  00000-0  24954-3 0  9991"
 const SatThreeLineTwo = "2 58247  43.0041  59.6580 0001638 274.8194  85.2461 15.02562597 66220"
 
-const CloseCollisionTime = 2460688.299389648
+var CloseCollisionTime = julianDateFromFloat(2460688.299389648)
+
 const CloseCollisionDistance = 0.21177285731942194
 
+// True minimum near CloseCollisionTime, found by sampling every 0.5 ms
+const CloseCollisionMinDistance = 0.153516
+
 func round4Decimals(num float64) float64 {
 	return math.Round(num*10000) / 10000.0
 }
 
-var satOne = NewSgp4Satellite(SatOneLineOne, SatOneLineTwo)
-var satTwo = NewSgp4Satellite(SatTwoLineOne, SatTwoLineTwo)
-var satThree = NewSgp4Satellite(SatThreeLineOne, SatThreeLineTwo)
+var satOne, _ = NewSgp4Satellite(SatOneLineOne, SatOneLineTwo)
+var satTwo, _ = NewSgp4Satellite(SatTwoLineOne, SatTwoLineTwo)
+var satThree, _ = NewSgp4Satellite(SatThreeLineOne, SatThreeLineTwo)
 
 func TestMatchPythonSgp4(t *testing.T) {
 
@@ -48,15 +53,62 @@ func TestCloseCollisionDistanceAtSpecificTime(t *testing.T) {
 	assert.Equal(t, round4Decimals(CloseCollisionDistance), round4Decimals(distance))
 }
 
-func TestFindCollisionTimeWithBinarySearch(t *testing.T) {
+func TestFindCollisionTimeWithBrent(t *testing.T) {
 	startTime := julianDateAddSeconds(CloseCollisionTime, -2*60)
 
 	timeLeft := julianDateAddSeconds(startTime, -10*60)
 	timeRight := julianDateAddSeconds(startTime, 10*60)
 
-	collisionTime, _ := binarySearch(satTwo, satThree, timeLeft, timeRight)
-	collisionDistance, _ := distanceBetweenSatellites(satTwo, satThree, collisionTime)
+	approaches, failures := findCloseApproaches(satTwo, satThree, timeLeft, timeRight, 60, 0.001)
+	assert.Zero(t, failures.total())
+	assert.Len(t, approaches, 1)
+
+	assert.InDelta(t, 0, differenceInSeconds(CloseCollisionTime, approaches[0].JulianTime), 0.5)
+	assert.InDelta(t, CloseCollisionMinDistance, approaches[0].Distance, 0.0005)
+}
+
+func TestStateVelocityMatchesPositionDerivative(t *testing.T) {
+	state, err := satTwo.stateAtTime(CloseCollisionTime)
+	assert.NoError(t, err)
+
+	before, _ := satTwo.propagateAtTime(julianDateAddSeconds(CloseCollisionTime, -0.5))
+	after, _ := satTwo.propagateAtTime(julianDateAddSeconds(CloseCollisionTime, 0.5))
+
+	assert.InDelta(t, after.X-before.X, state.Velocity.X, 1e-3)
+	assert.InDelta(t, after.Y-before.Y, state.Velocity.Y, 1e-3)
+	assert.InDelta(t, after.Z-before.Z, state.Velocity.Z, 1e-3)
+}
+
+func TestMeanElementsNearEpoch(t *testing.T) {
+	mean, err := satTwo.meanElementsAtTime(satTwo.epoch())
+	assert.NoError(t, err)
+
+	assert.InDelta(t, 43.0052, mean.Inclination, 1e-3)
+	assert.InDelta(t, 50.6716, mean.RAAN, 1e-3)
+	assert.InDelta(t, 0.0001256, mean.Eccentricity, 1e-6)
+}
+
+func TestInvalidTleReturnsInitError(t *testing.T) {
+	_, err := NewSgp4Satellite(SatTwoLineOne, "2 56700  43.0052  50.6716 0001256 262.8432")
+
+	var initErr *SatelliteInitError
+	assert.ErrorAs(t, err, &initErr)
+	assert.Equal(t, "56700", initErr.ObjectID)
+}
 
-	assert.Equal(t, round4Decimals(CloseCollisionTime), round4Decimals(collisionTime))
-	assert.Equal(t, round4Decimals(CloseCollisionDistance), round4Decimals(collisionDistance))
+func TestSatelliteApiDataCatalogNumbers(t *testing.T) {
+	var records []SatelliteApiData
+	err := json.Unmarshal([]byte(`[
+		{"OBJECT_ID": "2023-067N", "NORAD_CAT_ID": "56700"},
+		{"OBJECT_ID": "2030-001A", "NORAD_CAT_ID": 270000123},
+		{"OBJECT_ID": "1979-104"}
+	]`), &records)
+	assert.NoError(t, err)
+
+	assert.Equal(t, CatalogNumber(56700), records[0].NoradCatID)
+	assert.Equal(t, "270000123", records[1].NoradCatID.String())
+	assert.Equal(t, CatalogNumber(0), records[2].NoradCatID)
+
+	err = json.Unmarshal([]byte(`[{"NORAD_CAT_ID": "1000000000"}]`), &records)
+	assert.Error(t, err)
 }
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// SpatialIndex finds the pairs of satellites within a distance of each other
+// on all three axes at one time. Implementations trade build cost against
+// query cost differently, see the benchmarks, and are chosen with
+// tier_one.index in the config.
+type SpatialIndex interface {
+	// closePairs returns each pair once, in any order
+	closePairs(points []IndexPoint, maxDist float64) []SatPair
+}
+
+// IndexPoint is the position of a satellite, ID being its index in the
+// catalog
+type IndexPoint struct {
+	ID       int
+	Position SatPosition
+}
+
+const (
+	SpatialIndexGrid   = "grid"
+	SpatialIndexKDTree = "kdtree"
+	SpatialIndexSweep  = "sweep"
+)
+
+var spatialIndexNames = []string{SpatialIndexGrid, SpatialIndexKDTree, SpatialIndexSweep}
+
+func newSpatialIndex(config TierOneConfig) SpatialIndex {
+	switch config.Index {
+	case SpatialIndexKDTree:
+		return kdTreeIndex{}
+	case SpatialIndexSweep:
+		return sweepIndex{}
+	}
+	return gridIndex{boxSize: config.BoxSize}
+}
+
+func validateSpatialIndex(name string) error {
+	for _, known := range spatialIndexNames {
+		if name == known {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown spatial index %q, expected one of %v", name, spatialIndexNames)
+}
+
+func withinBox(p1, p2 SatPosition, maxDist float64) bool {
+	return math.Abs(p1.X-p2.X) <= maxDist && math.Abs(p1.Y-p2.Y) <= maxDist && math.Abs(p1.Z-p2.Z) <= maxDist
+}
+
+func axisValue(position SatPosition, axis int) float64 {
+	switch axis {
+	case 0:
+		return position.X
+	case 1:
+		return position.Y
+	}
+	return position.Z
+}
+
+// gridIndex hashes satellites into cubic cells of boxSize, widened to
+// maxDist when that is larger, and compares each cell with itself and its 26
+// neighbours
+type gridIndex struct {
+	boxSize float64
+}
+
+// Half of the 26 neighbouring cells, so each pair of cells is visited once
+var forwardNeighbours = func() []ClusterKey {
+	offsets := []ClusterKey{}
+	for dx := -1; dx <= 1; dx++ {
+		for dy := -1; dy <= 1; dy++ {
+			for dz := -1; dz <= 1; dz++ {
+				if dx > 0 || (dx == 0 && dy > 0) || (dx == 0 && dy == 0 && dz > 0) {
+					offsets = append(offsets, ClusterKey{X: dx, Y: dy, Z: dz})
+				}
+			}
+		}
+	}
+	return offsets
+}()
+
+func (g gridIndex) closePairs(points []IndexPoint, maxDist float64) []SatPair {
+	boxSize := math.Max(g.boxSize, maxDist)
+	cells := make(map[ClusterKey][]IndexPoint)
+	for _, point := range points {
+		key := createClusterKey(point.Position, boxSize)
+		cells[key] = append(cells[key], point)
+	}
+
+	pairs := []SatPair{}
+	for key, members := range cells {
+		for i := range members {
+			for j := i + 1; j < len(members); j++ {
+				if withinBox(members[i].Position, members[j].Position, maxDist) {
+					pairs = append(pairs, NewSatPair(members[i].ID, members[j].ID))
+				}
+			}
+		}
+
+		for _, offset := range forwardNeighbours {
+			neighbours, ok := cells[ClusterKey{X: key.X + offset.X, Y: key.Y + offset.Y, Z: key.Z + offset.Z}]
+			if !ok {
+				continue
+			}
+			for _, p1 := range members {
+				for _, p2 := range neighbours {
+					if withinBox(p1.Position, p2.Position, maxDist) {
+						pairs = append(pairs, NewSatPair(p1.ID, p2.ID))
+					}
+				}
+			}
+		}
+	}
+	return pairs
+}
+
+// kdTreeIndex builds a k-d tree in place, splitting on the median of the
+// widest axis, then runs a box query around every satellite
+type kdTreeIndex struct{}
+
+func (kdTreeIndex) closePairs(points []IndexPoint, maxDist float64) []SatPair {
+	// The tree is implicit: the node of a range sits at its midpoint with the
+	// subtrees either side, axes holds the split axis of each node
+	nodes := make([]IndexPoint, len(points))
+	copy(nodes, points)
+	axes := make([]int, len(points))
+	buildKDTree(nodes, axes)
+
+	pairs := []SatPair{}
+	var search func(query IndexPoint, nodes []IndexPoint, axes []int)
+	search = func(query IndexPoint, nodes []IndexPoint, axes []int) {
+		if len(nodes) == 0 {
+			return
+		}
+		mid := len(nodes) / 2
+		split := nodes[mid]
+		if query.ID < split.ID && withinBox(query.Position, split.Position, maxDist) {
+			pairs = append(pairs, NewSatPair(query.ID, split.ID))
+		}
+
+		value := axisValue(query.Position, axes[mid])
+		splitValue := axisValue(split.Position, axes[mid])
+		if value-maxDist <= splitValue {
+			search(query, nodes[:mid], axes[:mid])
+		}
+		if value+maxDist >= splitValue {
+			search(query, nodes[mid+1:], axes[mid+1:])
+		}
+	}
+
+	for _, query := range nodes {
+		search(query, nodes, axes)
+	}
+	return pairs
+}
+
+func buildKDTree(nodes []IndexPoint, axes []int) {
+	if len(nodes) <= 1 {
+		return
+	}
+
+	axis := widestAxis(nodes)
+	sort.Slice(nodes, func(i, j int) bool {
+		return axisValue(nodes[i].Position, axis) < axisValue(nodes[j].Position, axis)
+	})
+	mid := len(nodes) / 2
+	axes[mid] = axis
+
+	buildKDTree(nodes[:mid], axes[:mid])
+	buildKDTree(nodes[mid+1:], axes[mid+1:])
+}
+
+func widestAxis(points []IndexPoint) int {
+	low := [3]float64{math.Inf(1), math.Inf(1), math.Inf(1)}
+	high := [3]float64{math.Inf(-1), math.Inf(-1), math.Inf(-1)}
+	for _, point := range points {
+		for axis := 0; axis < 3; axis++ {
+			value := axisValue(point.Position, axis)
+			low[axis] = math.Min(low[axis], value)
+			high[axis] = math.Max(high[axis], value)
+		}
+	}
+
+	widest := 0
+	for axis := 1; axis < 3; axis++ {
+		if high[axis]-low[axis] > high[widest]-low[widest] {
+			widest = axis
+		}
+	}
+	return widest
+}
+
+// sweepIndex sorts once along the axis with the greatest spread and sweeps a
+// window of maxDist along it, checking the other two axes directly
+type sweepIndex struct{}
+
+func (sweepIndex) closePairs(points []IndexPoint, maxDist float64) []SatPair {
+	axis := widestAxis(points)
+
+	sorted := make([]IndexPoint, len(points))
+	copy(sorted, points)
+	sort.Slice(sorted, func(i, j int) bool {
+		return axisValue(sorted[i].Position, axis) < axisValue(sorted[j].Position, axis)
+	})
+
+	pairs := []SatPair{}
+	for i := range sorted {
+		start := axisValue(sorted[i].Position, axis)
+		for j := i + 1; j < len(sorted); j++ {
+			if axisValue(sorted[j].Position, axis)-start > maxDist {
+				break
+			}
+			if withinBox(sorted[i].Position, sorted[j].Position, maxDist) {
+				pairs = append(pairs, NewSatPair(sorted[i].ID, sorted[j].ID))
+			}
+		}
+	}
+	return pairs
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// Synthetic catalogs for the orbital regimes the indexes are chosen for
+func regimePoints(regime string, count int) []IndexPoint {
+	r := rand.New(rand.NewSource(1))
+	points := make([]IndexPoint, count)
+	for i := range points {
+		var radius, latitude float64
+		switch regime {
+		case "leo":
+			// Dense shells between 500 and 600 km at all inclinations
+			radius = 6878 + r.Float64()*100
+			latitude = math.Asin(r.Float64()*2 - 1)
+		case "geo":
+			// A thin ring near the equator
+			radius = 42164 + r.NormFloat64()*20
+			latitude = r.NormFloat64() * 0.001
+		default:
+			// Everything from LEO out to GEO
+			radius = 6700 + r.Float64()*36000
+			latitude = math.Asin(r.Float64()*2 - 1)
+		}
+		longitude := r.Float64() * 2 * math.Pi
+		points[i] = IndexPoint{ID: i, Position: SatPosition{
+			X: radius * math.Cos(latitude) * math.Cos(longitude),
+			Y: radius * math.Cos(latitude) * math.Sin(longitude),
+			Z: radius * math.Sin(latitude),
+		}}
+	}
+	return points
+}
+
+func BenchmarkSpatialIndex(b *testing.B) {
+	regimes := []struct {
+		name  string
+		count int
+	}{{"leo", 20000}, {"geo", 2000}, {"mixed", 30000}}
+
+	for _, regime := range regimes {
+		points := regimePoints(regime.name, regime.count)
+		for _, name := range spatialIndexNames {
+			config := defaultConfig().TierOne
+			config.Index = name
+			index := newSpatialIndex(config)
+
+			b.Run(regime.name+"/"+name, func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					index.closePairs(points, config.MaxDistance)
+				}
+			})
+		}
+	}
+}
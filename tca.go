@@ -0,0 +1,219 @@
+package main
+
+import (
+	"math"
+)
+
+// Relative position and velocity of sat2 from sat1, km and km/s
+func relativeState(sat1, sat2 Propagator, atTime JulianDate) (SatPosition, SatPosition, error) {
+	state1, err := sat1.stateAtTime(atTime)
+	if err != nil {
+		return SatPosition{}, SatPosition{}, err
+	}
+	state2, err := sat2.stateAtTime(atTime)
+	if err != nil {
+		return SatPosition{}, SatPosition{}, err
+	}
+	return subtractPositions(state2.Position, state1.Position), subtractPositions(SatPosition(state2.Velocity), SatPosition(state1.Velocity)), nil
+}
+
+// Range rate times range, the dot product of relative position and
+// velocity. It changes sign from negative to positive at each closest
+// approach.
+func rangeRate(sat1, sat2 Propagator, atTime JulianDate) (float64, error) {
+	position, velocity, err := relativeState(sat1, sat2, atTime)
+	if err != nil {
+		return 0, err
+	}
+	return dotProduct(position, velocity), nil
+}
+
+// findCloseApproaches returns every local minimum of the range between
+// timeLeft and timeRight, in time order. The range rate is sampled at most
+// scanSeconds apart to bracket its sign changes from negative to positive,
+// each of which is solved to toleranceSeconds with Brent's method. Minima
+// closer together than the scan step can be missed, and a range still
+// falling at timeRight or rising at timeLeft is not a minimum. A sample that
+// fails to propagate is counted in the failures and the scan carries on past
+// it, only the brackets either side of it are lost.
+func findCloseApproaches(sat1, sat2 Propagator, timeLeft, timeRight JulianDate, scanSeconds, toleranceSeconds float64) ([]MinDistancePoint, PropagationFailures) {
+	span := differenceInSeconds(timeLeft, timeRight)
+	steps := max(int(math.Ceil(span/scanSeconds)), 1)
+	step := span / float64(steps)
+
+	rateAt := func(seconds float64) (float64, error) {
+		return rangeRate(sat1, sat2, julianDateAddSeconds(timeLeft, seconds))
+	}
+
+	approaches := []MinDistancePoint{}
+	var failures PropagationFailures
+	previous, err := rateAt(0)
+	if err != nil {
+		failures.add(timeLeft, err)
+		previous = math.NaN()
+	}
+	for i := 1; i <= steps; i++ {
+		a, b := float64(i-1)*step, float64(i)*step
+		rate, err := rateAt(b)
+		if err != nil {
+			failures.add(julianDateAddSeconds(timeLeft, b), err)
+			previous = math.NaN()
+			continue
+		}
+
+		// NaN after a failed sample never brackets
+		if previous < 0 && rate >= 0 {
+			root, err := brentRoot(rateAt, a, b, previous, rate, toleranceSeconds)
+			var approach MinDistancePoint
+			if err == nil {
+				approach, err = closeApproachAt(sat1, sat2, julianDateAddSeconds(timeLeft, root))
+			}
+			if err != nil {
+				failures.add(julianDateAddSeconds(timeLeft, a), err)
+			} else {
+				approaches = append(approaches, approach)
+			}
+		}
+		previous = rate
+	}
+	return approaches, failures
+}
+
+// brentRoot finds a root of f in [a, b] to within tolerance, given f(a) and
+// f(b) of opposite signs
+func brentRoot(f func(float64) (float64, error), a, b, fa, fb, tolerance float64) (float64, error) {
+	if fb == 0 {
+		return b, nil
+	}
+
+	c, fc := a, fa
+	d := b - a
+	e := d
+	for {
+		// b is the best estimate and c the other side of the bracket
+		if fb*fc > 0 {
+			c, fc = a, fa
+			d = b - a
+			e = d
+		}
+		if math.Abs(fc) < math.Abs(fb) {
+			a, b, c = b, c, b
+			fa, fb, fc = fb, fc, fb
+		}
+
+		tol := 2*1e-15*math.Abs(b) + tolerance/2
+		m := (c - b) / 2
+		if math.Abs(m) <= tol || fb == 0 {
+			return b, nil
+		}
+
+		if math.Abs(e) >= tol && math.Abs(fa) > math.Abs(fb) {
+			// Secant or inverse quadratic interpolation
+			var p, q float64
+			s := fb / fa
+			if a == c {
+				p = 2 * m * s
+				q = 1 - s
+			} else {
+				q = fa / fc
+				r := fb / fc
+				p = s * (2*m*q*(q-r) - (b-a)*(r-1))
+				q = (q - 1) * (r - 1) * (s - 1)
+			}
+			if p > 0 {
+				q = -q
+			} else {
+				p = -p
+			}
+			if 2*p < math.Min(3*m*q-math.Abs(tol*q), math.Abs(e*q)) {
+				e = d
+				d = p / q
+			} else {
+				d = m
+				e = d
+			}
+		} else {
+			// Bisection
+			d = m
+			e = d
+		}
+
+		a, fa = b, fb
+		if math.Abs(d) > tol {
+			b += d
+		} else {
+			b += math.Copysign(tol, m)
+		}
+
+		var err error
+		fb, err = f(b)
+		if err != nil {
+			return 0, err
+		}
+	}
+}
+
+// Miss vector of the secondary from the primary in the primary's radial,
+// in-track and cross-track frame, km
+type RICVector struct {
+	Radial     float64 `yaml:"radial_km" json:"radial_km"`
+	InTrack    float64 `yaml:"in_track_km" json:"in_track_km"`
+	CrossTrack float64 `yaml:"cross_track_km" json:"cross_track_km"`
+}
+
+// Geometry of a closest approach, seen from the primary
+type Encounter struct {
+	Miss RICVector
+	// Speed of the secondary relative to the primary, km/s
+	RelativeSpeed float64
+	// Angle between the two velocities, degrees. 180 is head on.
+	ApproachAngle float64
+	// Probability of collision, when computed
+	Pc float64
+}
+
+// closeApproachAt measures the approach of sat2 to sat1 at atTime from their
+// full states
+func closeApproachAt(sat1, sat2 Propagator, atTime JulianDate) (MinDistancePoint, error) {
+	state1, err := sat1.stateAtTime(atTime)
+	if err != nil {
+		return MinDistancePoint{}, err
+	}
+	state2, err := sat2.stateAtTime(atTime)
+	if err != nil {
+		return MinDistancePoint{}, err
+	}
+
+	return MinDistancePoint{
+		JulianTime: atTime,
+		Distance:   distanceBetweenPositions(state1.Position, state2.Position),
+		Encounter:  encounterGeometry(state1, state2),
+		Primary:    state1,
+		Secondary:  state2,
+	}, nil
+}
+
+func encounterGeometry(primary, secondary SatState) Encounter {
+	position := primary.Position
+	velocity := SatPosition(primary.Velocity)
+	secondaryVelocity := SatPosition(secondary.Velocity)
+
+	radial := scalePosition(position, 1/vectorLength(position))
+	angularMomentum := crossProduct(position, velocity)
+	crossTrack := scalePosition(angularMomentum, 1/vectorLength(angularMomentum))
+	inTrack := crossProduct(crossTrack, radial)
+
+	miss := subtractPositions(secondary.Position, position)
+	relativeVelocity := subtractPositions(secondaryVelocity, velocity)
+	cosAngle := dotProduct(velocity, secondaryVelocity) / (vectorLength(velocity) * vectorLength(secondaryVelocity))
+
+	return Encounter{
+		Miss: RICVector{
+			Radial:     dotProduct(miss, radial),
+			InTrack:    dotProduct(miss, inTrack),
+			CrossTrack: dotProduct(miss, crossTrack),
+		},
+		RelativeSpeed: vectorLength(relativeVelocity),
+		ApproachAngle: math.Acos(math.Max(-1, math.Min(1, cosAngle))) * 180 / math.Pi,
+	}
+}
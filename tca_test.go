@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindCloseApproachesReturnsEveryMinimum(t *testing.T) {
+	at := createJulianDate(2025, 1, 12, 0, 0, 0)
+
+	// Same radius in planes 60 degrees apart, meeting at both nodes
+	sin, cos := math.Sincos(math.Pi / 3)
+	sat1 := &circularPropagator{id: "A", reference: at, radius: 7000, u: SatPosition{X: 1}, v: SatPosition{Y: 1}}
+	sat2 := &circularPropagator{id: "B", reference: at, radius: 7000, u: SatPosition{X: 1}, v: SatPosition{Y: cos, Z: sin}, phase: 0.001}
+	halfPeriod := math.Pi * math.Sqrt(7000*7000*7000/wgs72Mu)
+
+	approaches, failures := findCloseApproaches(sat1, sat2, julianDateAddSeconds(at, -600), julianDateAddSeconds(at, halfPeriod+600), 60, 0.001)
+	assert.Zero(t, failures.total())
+	assert.Len(t, approaches, 2)
+
+	for i, approach := range approaches {
+		// Each is a true minimum of the sampled range
+		before, _ := distanceBetweenSatellites(sat1, sat2, julianDateAddSeconds(approach.JulianTime, -0.01))
+		after, _ := distanceBetweenSatellites(sat1, sat2, julianDateAddSeconds(approach.JulianTime, 0.01))
+		assert.Less(t, approach.Distance, before, "approach %d", i)
+		assert.Less(t, approach.Distance, after, "approach %d", i)
+		assert.Less(t, approach.Distance, 10.0)
+	}
+	assert.InDelta(t, halfPeriod, differenceInSeconds(approaches[0].JulianTime, approaches[1].JulianTime), 60)
+}
+
+func TestFindCloseApproachesSkipsWindowEdges(t *testing.T) {
+	at := createJulianDate(2025, 1, 12, 0, 0, 0)
+	sat1 := &linearPropagator{id: "A", reference: at, position: SatPosition{X: 7000}, velocity: SatPosition{Y: 7}}
+	sat2 := &linearPropagator{id: "B", reference: at, position: SatPosition{X: 7001}, velocity: SatPosition{Y: -7}}
+
+	// Range only falls across the window, the minimum is after it
+	approaches, failures := findCloseApproaches(sat1, sat2, julianDateAddSeconds(at, -600), julianDateAddSeconds(at, -60), 60, 0.001)
+	assert.Zero(t, failures.total())
+	assert.Empty(t, approaches)
+
+	approaches, failures = findCloseApproaches(sat1, sat2, julianDateAddSeconds(at, -600), julianDateAddSeconds(at, 600), 60, 0.001)
+	assert.Zero(t, failures.total())
+	assert.Len(t, approaches, 1)
+	assert.InDelta(t, 0, differenceInSeconds(at, approaches[0].JulianTime), 0.001)
+	assert.InDelta(t, 1, approaches[0].Distance, 1e-6)
+}
+
+func TestEncounterGeometry(t *testing.T) {
+	primary := SatState{Position: SatPosition{X: 7000}, Velocity: SatVelocity{Y: 7.5}}
+	secondary := SatState{Position: SatPosition{X: 7001, Y: 0.5, Z: 0.2}, Velocity: SatVelocity{Y: -7.5}}
+
+	encounter := encounterGeometry(primary, secondary)
+	assert.InDelta(t, 1, encounter.Miss.Radial, 1e-12)
+	assert.InDelta(t, 0.5, encounter.Miss.InTrack, 1e-12)
+	assert.InDelta(t, 0.2, encounter.Miss.CrossTrack, 1e-12)
+	assert.InDelta(t, 15, encounter.RelativeSpeed, 1e-12)
+	assert.InDelta(t, 180, encounter.ApproachAngle, 1e-9)
+
+	// Crossing at right angles, out of plane
+	secondary.Velocity = SatVelocity{Z: 7.5}
+	encounter = encounterGeometry(primary, secondary)
+	assert.InDelta(t, 7.5*math.Sqrt2, encounter.RelativeSpeed, 1e-12)
+	assert.InDelta(t, 90, encounter.ApproachAngle, 1e-9)
+}
+
+// Fails between from and to, like a propagation that briefly diverges
+type gapPropagator struct {
+	linearPropagator
+	from, to JulianDate
+}
+
+func (g *gapPropagator) stateAtTime(julianDate JulianDate) (SatState, error) {
+	if g.from.before(julianDate) && julianDate.before(g.to) {
+		return SatState{}, fmt.Errorf("propagation error: %w", errSgp4Eccentricity)
+	}
+	return g.linearPropagator.stateAtTime(julianDate)
+}
+
+func (g *gapPropagator) propagateAtTime(julianDate JulianDate) (SatPosition, error) {
+	state, err := g.stateAtTime(julianDate)
+	return state.Position, err
+}
+
+func TestFindCloseApproachesScansPastFailures(t *testing.T) {
+	at := createJulianDate(2025, 1, 12, 0, 0, 0)
+	sat1 := &gapPropagator{
+		linearPropagator: linearPropagator{id: "A", reference: at, position: SatPosition{X: 7000}, velocity: SatPosition{Y: 7}},
+		from:             julianDateAddSeconds(at, -310),
+		to:               julianDateAddSeconds(at, -290),
+	}
+	sat2 := &linearPropagator{id: "B", reference: at, position: SatPosition{X: 7001}, velocity: SatPosition{Y: -7}}
+
+	// Only the sample 300 s before the approach fails
+	approaches, failures := findCloseApproaches(sat1, sat2, julianDateAddSeconds(at, -600), julianDateAddSeconds(at, 600), 60, 0.001)
+	assert.Len(t, approaches, 1)
+	assert.InDelta(t, 0, differenceInSeconds(at, approaches[0].JulianTime), 0.001)
+	assert.Equal(t, 1, failures.total())
+	assert.Equal(t, 1, failures.Counts[FailureBadElements])
+
+	// Tier two keeps the event and reports the pair
+	config := defaultConfig()
+	events := tierTwoCollisionsWithWorkerPool([][]SatPair{{{ID1: 0, ID2: 1}}}, nil, []JulianDate{at}, []Propagator{sat1, sat2}, config.TierTwo)
+	assert.Len(t, events.pairEvents(0, 1), 1)
+	assert.Equal(t, 1, events.Failures[SatPair{ID1: 0, ID2: 1}].total())
+}
@@ -1,9 +1,10 @@
 package main
 
 import (
-	"sort"
+	"math"
 )
 
+// Defaults for TierOneConfig
 const BOX_SIZE = 1200
 const MAX_DIST = 100
 
@@ -11,143 +12,40 @@ type ClusterKey struct {
 	X, Y, Z int
 }
 
-type SatCoord struct {
-	SatelliteID int
-	Dim         float64
-}
-
+// TimeCluster finds the at risk pairs at one time of the ephemeris with the
+// configured spatial index
 type TimeCluster struct {
 	TimeIndex    int
 	SatCount     int
 	SatLocations [][]SatPosition
-	Clusters     map[ClusterKey][]int
+	Valid        [][]bool
+	Index        SpatialIndex
+	MaxDist      float64
 }
 
-func NewTimeCluster(timeIndex, satCount int, satLocations [][]SatPosition) *TimeCluster {
+func NewTimeCluster(timeIndex, satCount int, ephemeris *Ephemeris, config TierOneConfig) *TimeCluster {
 	return &TimeCluster{
 		TimeIndex:    timeIndex,
 		SatCount:     satCount,
-		SatLocations: satLocations,
-		Clusters:     make(map[ClusterKey][]int),
-	}
-}
-
-func (t *TimeCluster) buildClusters() {
-
-	for i := 0; i < t.SatCount; i++ {
-		position := t.SatLocations[i][t.TimeIndex]
-		clusterKey := createClusterKey(position)
-
-		if _, ok := t.Clusters[clusterKey]; !ok {
-			t.Clusters[clusterKey] = []int{i}
-		} else {
-			t.Clusters[clusterKey] = append(t.Clusters[clusterKey], i)
-		}
+		SatLocations: ephemeris.Positions,
+		Valid:        ephemeris.Valid,
+		Index:        ephemeris.spatialIndex(config),
+		MaxDist:      config.MaxDistance,
 	}
 }
 
 func (t *TimeCluster) getAtRiskPairs() []SatPair {
 
-	if len(t.Clusters) == 0 {
-		t.buildClusters()
-	}
-
-	atRiskPairSet := make(map[SatPair]struct{})
-
-	for clusterKey := range t.Clusters {
-		allSatIndexes := t.getAllSatIdsInNeighborCluster(clusterKey)
-		closePairs := t.getClosePairs(allSatIndexes)
-		for pair := range closePairs {
-			atRiskPairSet[pair] = struct{}{}
-		}
-	}
-
-	atRiskPairs := []SatPair{}
-	for pair := range atRiskPairSet {
-		atRiskPairs = append(atRiskPairs, pair)
-	}
-
-	return atRiskPairs
-}
-
-func (t *TimeCluster) getClosePairs(satIndexes []int) map[SatPair]struct{} {
-
-	xCoords := []SatCoord{}
-	yCoords := []SatCoord{}
-	zCoords := []SatCoord{}
-
-	for _, satIndex := range satIndexes {
-		satPosition := t.SatLocations[satIndex][t.TimeIndex]
-		xCoords = append(xCoords, SatCoord{SatelliteID: satIndex, Dim: satPosition.X})
-		yCoords = append(yCoords, SatCoord{SatelliteID: satIndex, Dim: satPosition.Y})
-		zCoords = append(zCoords, SatCoord{SatelliteID: satIndex, Dim: satPosition.Z})
-	}
-
-	xPairs := t.findDimPairs(xCoords)
-	yPairs := t.findDimPairs(yCoords)
-	zPairs := t.findDimPairs(zCoords)
-
-	intersection := intersectSets(xPairs, yPairs)
-	intersection = intersectSets(intersection, zPairs)
-
-	// Filter out pairs that are 0 distance
-	closePairs := make(map[SatPair]struct{})
-	for pair := range intersection {
-		pos1 := t.SatLocations[pair.ID1][t.TimeIndex]
-		pos2 := t.SatLocations[pair.ID2][t.TimeIndex]
-		dist := distanceBetweenPositions(pos1, pos2)
-		if dist != 0 {
-			closePairs[pair] = struct{}{}
-		}
-	}
-
-	return closePairs
-}
-
-func (t *TimeCluster) getAllSatIdsInNeighborCluster(clusterKey ClusterKey) []int {
-
-	// Make sure to include the cluster itself
-	neighbors := []ClusterKey{
-		{X: clusterKey.X, Y: clusterKey.Y, Z: clusterKey.Z},
-		{X: clusterKey.X + 1, Y: clusterKey.Y, Z: clusterKey.Z},
-		{X: clusterKey.X - 1, Y: clusterKey.Y, Z: clusterKey.Z},
-		{X: clusterKey.X, Y: clusterKey.Y + 1, Z: clusterKey.Z},
-		{X: clusterKey.X, Y: clusterKey.Y - 1, Z: clusterKey.Z},
-		{X: clusterKey.X, Y: clusterKey.Y, Z: clusterKey.Z + 1},
-		{X: clusterKey.X, Y: clusterKey.Y, Z: clusterKey.Z - 1},
-	}
-
-	allSatIndexes := []int{}
-	for _, neighborKey := range neighbors {
-		if _, ok := t.Clusters[neighborKey]; ok {
-			allSatIndexes = append(allSatIndexes, t.Clusters[neighborKey]...)
-		}
-	}
-
-	return allSatIndexes
-}
-
-func (t *TimeCluster) findDimPairs(satCoords []SatCoord) map[SatPair]struct{} {
-
-	// First sort the coords
-	sort.Slice(satCoords, func(i, j int) bool {
-		return satCoords[i].Dim < satCoords[j].Dim
-	})
-
-	satPairs := make(map[SatPair]struct{})
-
-	// Then find the pairs
-	for i := 0; i < len(satCoords); i++ {
-		for j := i + 1; j < len(satCoords); j++ {
-			if satCoords[j].Dim-satCoords[i].Dim <= MAX_DIST {
-				satPairs[NewSatPair(satCoords[i].SatelliteID, satCoords[j].SatelliteID)] = struct{}{}
-			} else {
-				break
-			}
+	points := make([]IndexPoint, 0, t.SatCount)
+	for i := 0; i < t.SatCount; i++ {
+		// Satellites that failed to propagate at this time take no part
+		if !t.Valid[i][t.TimeIndex] {
+			continue
 		}
+		points = append(points, IndexPoint{ID: i, Position: t.SatLocations[i][t.TimeIndex]})
 	}
 
-	return satPairs
+	return t.Index.closePairs(points, t.MaxDist)
 }
 
 type SatPair struct {
@@ -162,20 +60,11 @@ func NewSatPair(id1, id2 int) SatPair {
 	return SatPair{ID1: id1, ID2: id2}
 }
 
-// TODO: change this to use struct
-func createClusterKey(position SatPosition) ClusterKey {
-	xIndex := int(position.X / BOX_SIZE)
-	yIndex := int(position.Y / BOX_SIZE)
-	zIndex := int(position.Z / BOX_SIZE)
+// Cells are [k*boxSize, (k+1)*boxSize) on each axis. Flooring rather than
+// truncating keeps the cells either side of zero the same width.
+func createClusterKey(position SatPosition, boxSize float64) ClusterKey {
+	xIndex := int(math.Floor(position.X / boxSize))
+	yIndex := int(math.Floor(position.Y / boxSize))
+	zIndex := int(math.Floor(position.Z / boxSize))
 	return ClusterKey{X: xIndex, Y: yIndex, Z: zIndex}
 }
-
-func intersectSets(set1, set2 map[SatPair]struct{}) map[SatPair]struct{} {
-	intersection := make(map[SatPair]struct{})
-	for pair := range set1 {
-		if _, exists := set2[pair]; exists {
-			intersection[pair] = struct{}{}
-		}
-	}
-	return intersection
-}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+	"testing/quick"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Random satellite positions packed into a few cells around the origin, so
+// pairs straddle faces, edges, corners and the zero planes
+type randomPositions []SatPosition
+
+func (randomPositions) Generate(r *rand.Rand, size int) reflect.Value {
+	positions := make(randomPositions, 50+r.Intn(250))
+	extent := 3.0 * BOX_SIZE
+	for i := range positions {
+		positions[i] = SatPosition{
+			X: (r.Float64()*2 - 1) * extent,
+			Y: (r.Float64()*2 - 1) * extent,
+			Z: (r.Float64()*2 - 1) * extent,
+		}
+		// Some satellites sit exactly on a cell boundary
+		if r.Intn(10) == 0 {
+			positions[i].X = math.Round(positions[i].X/BOX_SIZE) * BOX_SIZE
+		}
+	}
+	return reflect.ValueOf(positions)
+}
+
+func sortPairs(pairs []SatPair) []SatPair {
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].ID1 != pairs[j].ID1 {
+			return pairs[i].ID1 < pairs[j].ID1
+		}
+		return pairs[i].ID2 < pairs[j].ID2
+	})
+	return pairs
+}
+
+// Ephemeris with a single time
+func singleTimeEphemeris(positions []SatPosition) *Ephemeris {
+	ephemeris := &Ephemeris{}
+	for _, position := range positions {
+		ephemeris.Positions = append(ephemeris.Positions, []SatPosition{position})
+		ephemeris.Valid = append(ephemeris.Valid, []bool{true})
+	}
+	return ephemeris
+}
+
+func timeClusterPairs(positions []SatPosition, config TierOneConfig) []SatPair {
+	return NewTimeCluster(0, len(positions), singleTimeEphemeris(positions), config).getAtRiskPairs()
+}
+
+func TestTimeClusterMatchesBruteForce(t *testing.T) {
+	// Cells as wide as the distance are the tightest case for the grid, as
+	// well as the default ratio
+	configs := []TierOneConfig{
+		{BoxSize: BOX_SIZE, MaxDistance: BOX_SIZE},
+		{BoxSize: BOX_SIZE, MaxDistance: MAX_DIST * 5},
+	}
+
+	for _, index := range spatialIndexNames {
+		for _, config := range configs {
+			config.Index = index
+			property := func(positions randomPositions) bool {
+				expected := sortPairs(bruteForcePairsAtTime(0, len(positions), singleTimeEphemeris(positions), config.MaxDistance))
+				actual := sortPairs(timeClusterPairs(positions, config))
+				return assert.Equal(t, expected, actual, index)
+			}
+			err := quick.Check(property, &quick.Config{MaxCount: 50, Rand: rand.New(rand.NewSource(1))})
+			assert.NoError(t, err, index)
+		}
+	}
+}
+
+func TestClusterKeyFloorsNegativeCoordinates(t *testing.T) {
+	assert.Equal(t, ClusterKey{X: -1, Y: 0, Z: -2}, createClusterKey(SatPosition{X: -0.5, Y: 0.5, Z: -1200.5}, 1200))
+	assert.Equal(t, ClusterKey{X: 1, Y: -1, Z: 0}, createClusterKey(SatPosition{X: 1200, Y: -1200, Z: 0}, 1200))
+}
+
+func TestDiagonalNeighboursAreSearched(t *testing.T) {
+	// Opposite corners of four cells meeting at (1200, 1200, 1200)
+	positions := []SatPosition{
+		{X: 1199, Y: 1199, Z: 1199},
+		{X: 1201, Y: 1201, Z: 1201},
+	}
+	assert.Equal(t, []SatPair{{ID1: 0, ID2: 1}}, timeClusterPairs(positions, defaultConfig().TierOne))
+}
@@ -0,0 +1,372 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// UTC Julian dates follow the SOFA convention: the day fraction of a day with
+// a leap second spans 86,401 s, so 23:59:60 has a Julian date. Every UTC date
+// in the program uses it, and julianDateAddSeconds and differenceInSeconds
+// count the extra second.
+
+// TT runs ahead of TAI by a fixed offset
+const ttMinusTAI = 32.184
+
+// Julian date of the NTP epoch, 1900-01-01, used by leap-seconds.list
+const ntpEpochJulianDate = 2415020.5
+
+// LeapSecond is the TAI-UTC offset in force from a UTC midnight onwards
+type LeapSecond struct {
+	Midnight    float64 // Julian date
+	TAIMinusUTC float64
+}
+
+// Built-in table from IERS Bulletin C, up to the leap second at the end of
+// 2016. Replace it with loadLeapSeconds when a newer one is announced.
+var leapSecondTable = buildLeapSecondTable([][3]int{
+	{1972, 1, 10}, {1972, 7, 11}, {1973, 1, 12}, {1974, 1, 13}, {1975, 1, 14},
+	{1976, 1, 15}, {1977, 1, 16}, {1978, 1, 17}, {1979, 1, 18}, {1980, 1, 19},
+	{1981, 7, 20}, {1982, 7, 21}, {1983, 7, 22}, {1985, 7, 23}, {1988, 1, 24},
+	{1990, 1, 25}, {1991, 1, 26}, {1992, 7, 27}, {1993, 7, 28}, {1994, 7, 29},
+	{1996, 1, 30}, {1997, 7, 31}, {1999, 1, 32}, {2006, 1, 33}, {2009, 1, 34},
+	{2012, 7, 35}, {2015, 7, 36}, {2017, 1, 37},
+})
+
+// Entries are year, month and TAI-UTC from the first of that month
+func buildLeapSecondTable(entries [][3]int) []LeapSecond {
+	table := make([]LeapSecond, len(entries))
+	for i, entry := range entries {
+		table[i] = LeapSecond{
+			Midnight:    createJulianDate(entry[0], entry[1], 1, 0, 0, 0).Day,
+			TAIMinusUTC: float64(entry[2]),
+		}
+	}
+	return table
+}
+
+// loadLeapSeconds reads a leap-seconds.list file as published by IERS and
+// NIST: NTP timestamp and TAI-UTC per line, # starts a comment
+func loadLeapSeconds(r io.Reader) ([]LeapSecond, error) {
+	table := []LeapSecond{}
+	scanner := bufio.NewScanner(r)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("leap seconds line %d: expected timestamp and offset", lineNumber)
+		}
+
+		ntpSeconds, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("leap seconds line %d: invalid timestamp %q", lineNumber, fields[0])
+		}
+		offset, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("leap seconds line %d: invalid offset %q", lineNumber, fields[1])
+		}
+		table = append(table, LeapSecond{
+			Midnight:    ntpEpochJulianDate + float64(ntpSeconds)/86400.0,
+			TAIMinusUTC: float64(offset),
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(table) == 0 {
+		return nil, fmt.Errorf("leap seconds file has no entries")
+	}
+
+	sort.Slice(table, func(i, j int) bool {
+		return table[i].Midnight < table[j].Midnight
+	})
+	return table, nil
+}
+
+func useLeapSecondsFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	table, err := loadLeapSeconds(file)
+	if err != nil {
+		return err
+	}
+	leapSecondTable = table
+	return nil
+}
+
+// taiMinusUTC is the offset in seconds on the UTC day starting at midnight.
+// Dates before 1972 use the 1972 value, the drifting offsets of the 1960s are
+// not modelled.
+func taiMinusUTC(midnight float64) float64 {
+	i := sort.Search(len(leapSecondTable), func(i int) bool {
+		return leapSecondTable[i].Midnight > midnight
+	})
+	if i == 0 {
+		return leapSecondTable[0].TAIMinusUTC
+	}
+	return leapSecondTable[i-1].TAIMinusUTC
+}
+
+// leapSecondBetween reports whether a leap second ends any UTC day from the
+// earlier date to the later, where uniform days would be wrong
+func leapSecondBetween(julianDate1, julianDate2 JulianDate) bool {
+	first, last := julianDate1.Day, julianDate2.Day
+	if last < first {
+		first, last = last, first
+	}
+	// Nothing is known past the end of the table
+	if first >= leapSecondTable[len(leapSecondTable)-1].Midnight {
+		return false
+	}
+	return taiMinusUTC(first) != taiMinusUTC(last+1)
+}
+
+// utcDayLength is the length in seconds of the UTC day starting at midnight,
+// 86,401 when it ends with a leap second
+func utcDayLength(midnight float64) float64 {
+	return 86400.0 + taiMinusUTC(midnight+1) - taiMinusUTC(midnight)
+}
+
+func utcToTAI(julianDate JulianDate) JulianDate {
+	seconds := julianDate.Fraction * utcDayLength(julianDate.Day)
+	return newJulianDate(julianDate.Day, (seconds+taiMinusUTC(julianDate.Day))/86400.0)
+}
+
+func taiToUTC(julianDate JulianDate) JulianDate {
+	midnight := addDaySeconds(julianDate, -taiMinusUTC(julianDate.Day)).Day
+	secondsAfter := func(midnight float64) float64 {
+		return ((julianDate.Day-midnight)+julianDate.Fraction)*86400.0 - taiMinusUTC(midnight)
+	}
+
+	seconds := secondsAfter(midnight)
+	if seconds < 0 {
+		midnight--
+		seconds = secondsAfter(midnight)
+	} else if seconds >= utcDayLength(midnight) {
+		midnight++
+		seconds = secondsAfter(midnight)
+	}
+	return JulianDate{Day: midnight, Fraction: seconds / utcDayLength(midnight)}
+}
+
+func taiToTT(julianDate JulianDate) JulianDate {
+	return addDaySeconds(julianDate, ttMinusTAI)
+}
+
+func ttToTAI(julianDate JulianDate) JulianDate {
+	return addDaySeconds(julianDate, -ttMinusTAI)
+}
+
+func utcToTT(julianDate JulianDate) JulianDate {
+	return taiToTT(utcToTAI(julianDate))
+}
+
+func ttToUTC(julianDate JulianDate) JulianDate {
+	return taiToUTC(ttToTAI(julianDate))
+}
+
+// utcToUT1 applies DUT1 = UT1-UTC in seconds, as published in IERS
+// Bulletin A
+func utcToUT1(julianDate JulianDate, dut1 float64) JulianDate {
+	seconds := julianDate.Fraction*utcDayLength(julianDate.Day) + dut1
+	return newJulianDate(julianDate.Day, seconds/86400.0)
+}
+
+// isoToJulianDate parses an ISO-8601 UTC timestamp in calendar
+// (2025-01-12T06:30:00.5Z) or ordinal (2025-012T06:30:00.5Z) form. The time
+// may be left out, and 60 seconds is accepted on a leap second day.
+func isoToJulianDate(text string) (JulianDate, error) {
+	invalid := fmt.Errorf("invalid ISO-8601 time %q", text)
+
+	value := strings.TrimSpace(text)
+	value = strings.TrimSuffix(strings.TrimSuffix(value, "Z"), "+00:00")
+	datePart, timePart, _ := strings.Cut(value, "T")
+
+	midnight, err := isoDateToJulianDate(datePart)
+	if err != nil {
+		return JulianDate{}, invalid
+	}
+
+	var hour, minute int
+	var second float64
+	if timePart != "" {
+		parts := strings.Split(timePart, ":")
+		if len(parts) < 2 || len(parts) > 3 {
+			return JulianDate{}, invalid
+		}
+		hour, err = strconv.Atoi(parts[0])
+		if err != nil || hour > 23 || len(parts[0]) != 2 {
+			return JulianDate{}, invalid
+		}
+		minute, err = strconv.Atoi(parts[1])
+		if err != nil || minute > 59 || len(parts[1]) != 2 {
+			return JulianDate{}, invalid
+		}
+		if len(parts) == 3 {
+			second, err = strconv.ParseFloat(parts[2], 64)
+			if err != nil || second < 0 || len(parts[2]) < 2 || (parts[2][2:] != "" && parts[2][2] != '.') {
+				return JulianDate{}, invalid
+			}
+		}
+	}
+
+	dayLength := utcDayLength(midnight)
+	seconds := float64(hour*3600+minute*60) + second
+	leapSecond := hour == 23 && minute == 59 && dayLength > 86400
+	if second >= 61 || (second >= 60 && !leapSecond) || seconds >= dayLength {
+		return JulianDate{}, fmt.Errorf("invalid ISO-8601 time %q: second out of range", text)
+	}
+	return JulianDate{Day: midnight, Fraction: seconds / dayLength}, nil
+}
+
+// Julian date at midnight of a YYYY-MM-DD or YYYY-DDD date
+func isoDateToJulianDate(date string) (float64, error) {
+	parts := strings.Split(date, "-")
+	if len(parts[0]) != 4 {
+		return 0, fmt.Errorf("invalid year")
+	}
+	year, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, err
+	}
+
+	switch {
+	case len(parts) == 2 && len(parts[1]) == 3:
+		dayOfYear, err := strconv.Atoi(parts[1])
+		if err != nil || dayOfYear < 1 || dayOfYear > daysInYear(year) {
+			return 0, fmt.Errorf("invalid day of year")
+		}
+		return createJulianDate(year, 1, 1, 0, 0, 0).Day + float64(dayOfYear-1), nil
+	case len(parts) == 3 && len(parts[1]) == 2 && len(parts[2]) == 2:
+		month, err1 := strconv.Atoi(parts[1])
+		day, err2 := strconv.Atoi(parts[2])
+		if err1 != nil || err2 != nil || month < 1 || month > 12 || day < 1 || day > daysInMonth(year, month) {
+			return 0, fmt.Errorf("invalid date")
+		}
+		return createJulianDate(year, month, day, 0, 0, 0).Day, nil
+	}
+	return 0, fmt.Errorf("invalid date")
+}
+
+func daysInYear(year int) int {
+	if year%4 == 0 && (year%100 != 0 || year%400 == 0) {
+		return 366
+	}
+	return 365
+}
+
+var monthDays = [12]int{31, 28, 31, 30, 31, 30, 31, 31, 30, 31, 30, 31}
+
+func daysInMonth(year, month int) int {
+	if month == 2 && daysInYear(year) == 366 {
+		return 29
+	}
+	return monthDays[month-1]
+}
+
+// julianDateToISO formats a UTC Julian date as 2025-01-12T07:11:07.263Z with
+// the given number of decimals on the seconds, 23:59:60 on a leap second
+func julianDateToISO(julianDate JulianDate, decimals int) string {
+	midnight := julianDate.Day
+	dayLength := utcDayLength(midnight)
+
+	// Round first so 59.9996 carries into the next minute
+	scale := math.Pow(10, float64(decimals))
+	units := math.Round(julianDate.Fraction * dayLength * scale)
+	if units >= dayLength*scale {
+		midnight++
+		units = 0
+	}
+
+	seconds := units / scale
+	hour := int(seconds / 3600)
+	if hour > 23 {
+		hour = 23
+	}
+	minute := int((seconds - float64(hour*3600)) / 60)
+	if minute > 59 {
+		minute = 59
+	}
+	second := seconds - float64(hour*3600+minute*60)
+
+	year, month, day := julianDateToCalendar(midnight)
+	secondWidth := 2
+	if decimals > 0 {
+		secondWidth = 3 + decimals
+	}
+	return fmt.Sprintf("%04d-%02d-%02dT%02d:%02d:%0*.*fZ", year, month, day, hour, minute, secondWidth, decimals, second)
+}
+
+// julianDateToCalendar returns the Gregorian date of the day starting at a
+// Julian date midnight (Meeus, Astronomical Algorithms ch. 7)
+func julianDateToCalendar(midnight float64) (year, month, day int) {
+	z := int(math.Floor(midnight + 0.5))
+	alpha := int((float64(z) - 1867216.25) / 36524.25)
+	a := z + 1 + alpha - alpha/4
+	b := a + 1524
+	c := int((float64(b) - 122.1) / 365.25)
+	d := int(365.25 * float64(c))
+	e := int(float64(b-d) / 30.6001)
+
+	day = b - d - int(30.6001*float64(e))
+	if e < 14 {
+		month = e - 1
+	} else {
+		month = e - 13
+	}
+	if month > 2 {
+		year = c - 4716
+	} else {
+		year = c - 4715
+	}
+	return year, month, day
+}
+
+// Two digit TLE years follow the convention of 1957 to 2056
+func tleEpochYear(twoDigitYear int) int {
+	if twoDigitYear < 57 {
+		return twoDigitYear + 2000
+	}
+	return twoDigitYear + 1900
+}
+
+// tleEpochToJulianDate converts a TLE epoch, day 1.0 being midnight on
+// January 1st, keeping the day fraction exactly as written
+func tleEpochToJulianDate(year int, dayOfYear float64) JulianDate {
+	wholeDays := math.Floor(dayOfYear)
+	yearStart := createJulianDate(year, 1, 1, 0, 0, 0).Day - 1
+	return JulianDate{Day: yearStart + wholeDays, Fraction: dayOfYear - wholeDays}
+}
+
+// parseTLEEpoch reads the YYDDD.DDDDDDDD epoch of TLE line 1, columns 19-32
+func parseTLEEpoch(text string) (JulianDate, error) {
+	text = strings.TrimSpace(text)
+	if len(text) < 5 {
+		return JulianDate{}, fmt.Errorf("invalid TLE epoch %q", text)
+	}
+	twoDigitYear, err := strconv.Atoi(text[:2])
+	if err != nil {
+		return JulianDate{}, fmt.Errorf("invalid TLE epoch year %q", text[:2])
+	}
+	dayOfYear, err := strconv.ParseFloat(text[2:], 64)
+	if err != nil || dayOfYear < 1 || dayOfYear >= float64(daysInYear(tleEpochYear(twoDigitYear))+1) {
+		return JulianDate{}, fmt.Errorf("invalid TLE epoch day %q", text[2:])
+	}
+	return tleEpochToJulianDate(tleEpochYear(twoDigitYear), dayOfYear), nil
+}
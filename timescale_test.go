@@ -0,0 +1,94 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestISOJulianDateRoundTrip(t *testing.T) {
+	jd, err := isoToJulianDate("2025-01-12T00:00:00Z")
+	assert.NoError(t, err)
+	assert.Equal(t, createJulianDate(2025, 1, 12, 0, 0, 0), jd)
+
+	ordinal, err := isoToJulianDate("2025-012")
+	assert.NoError(t, err)
+	assert.Equal(t, jd, ordinal)
+
+	assert.Equal(t, "2025-01-12T19:11:07.266Z", julianDateToISO(CloseCollisionTime, 3))
+	assert.Equal(t, "2025-01-12T00:00:00Z", julianDateToISO(jd, 0))
+
+	for _, text := range []string{"2024-02-29T12:30:15.125Z", "1999-12-31T23:59:59.999", "2025-365T06:00:00Z"} {
+		jd, err := isoToJulianDate(text)
+		assert.NoError(t, err, text)
+		again, err := isoToJulianDate(julianDateToISO(jd, 3))
+		assert.NoError(t, err, text)
+		assert.InDelta(t, 0, differenceInSeconds(jd, again), 1e-6, text)
+	}
+
+	for _, text := range []string{"2025-02-29", "2025-01-12T24:00:00Z", "2025-01-12T00:00:60Z", "12/01/2025", "2025-01-12T06:00:00+02:00"} {
+		_, err := isoToJulianDate(text)
+		assert.Error(t, err, text)
+	}
+}
+
+func TestLeapSecondBoundary(t *testing.T) {
+	leap, err := isoToJulianDate("2016-12-31T23:59:60.5Z")
+	assert.NoError(t, err)
+	assert.Equal(t, "2016-12-31T23:59:60.500Z", julianDateToISO(leap, 3))
+
+	before, _ := isoToJulianDate("2016-12-31T23:59:59Z")
+	after, _ := isoToJulianDate("2017-01-01T00:00:00Z")
+	assert.InDelta(t, 2.0, differenceInSeconds(before, after), 1e-9)
+
+	assert.Equal(t, 36.0, taiMinusUTC(before.Day))
+	assert.Equal(t, 37.0, taiMinusUTC(after.Day))
+	assert.InDelta(t, 0, differenceInSeconds(leap, taiToUTC(utcToTAI(leap))), 1e-6)
+
+	// Steps across the leap second count it
+	assert.Equal(t, "2016-12-31T23:59:60.500Z", julianDateToISO(julianDateAddSeconds(before, 1.5), 3))
+	assert.Equal(t, "2017-01-01T00:00:00.000Z", julianDateToISO(julianDateAddSeconds(before, 2), 3))
+	noon, _ := isoToJulianDate("2016-12-31T12:00:00Z")
+	assert.Equal(t, "2016-12-31T12:00:00.000Z", julianDateToISO(timeToJulianDate(julianDateToTime(noon)), 3))
+}
+
+func TestTimeScales(t *testing.T) {
+	utc := createJulianDate(2025, 1, 12, 0, 0, 0)
+
+	assert.InDelta(t, 37.0, differenceInSeconds(utc, utcToTAI(utc)), 1e-9)
+	assert.InDelta(t, 69.184, differenceInSeconds(utc, utcToTT(utc)), 1e-9)
+	assert.InDelta(t, -0.1, differenceInSeconds(utc, utcToUT1(utc, -0.1)), 1e-9)
+	assert.InDelta(t, 0, differenceInSeconds(utc, ttToUTC(utcToTT(utc))), 1e-9)
+}
+
+func TestTLEEpochToJulianDate(t *testing.T) {
+	epoch, err := parseTLEEpoch("25011.12006866")
+	assert.NoError(t, err)
+
+	tle, _ := ParseTLE(SatTwoLineOne, SatTwoLineTwo)
+	assert.Equal(t, tle.EpochJulianDate(), epoch)
+	assert.Equal(t, "2025-01-11T02:52:53.932Z", julianDateToISO(epoch, 3))
+
+	epoch, err = parseTLEEpoch("98001.00000000")
+	assert.NoError(t, err)
+	assert.Equal(t, createJulianDate(1998, 1, 1, 0, 0, 0), epoch)
+
+	_, err = parseTLEEpoch("25367.0")
+	assert.Error(t, err)
+}
+
+func TestLoadLeapSeconds(t *testing.T) {
+	text := "#\tleap-seconds.list\n#@\t3960057600\n" +
+		"2272060800\t10\t# 1 Jan 1972\n" +
+		"3692217600\t37\t# 1 Jan 2017\n"
+
+	table, err := loadLeapSeconds(strings.NewReader(text))
+	assert.NoError(t, err)
+	assert.Len(t, table, 2)
+	assert.Equal(t, leapSecondTable[0], table[0])
+	assert.Equal(t, leapSecondTable[len(leapSecondTable)-1], table[1])
+
+	_, err = loadLeapSeconds(strings.NewReader("2272060800\n"))
+	assert.Error(t, err)
+}
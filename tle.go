@@ -0,0 +1,438 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Minutes per day over 2 pi, converts rev/day to rad/min
+const xpdotp = 1440.0 / (2.0 * math.Pi)
+
+const tleLineLength = 69
+
+// TLE is a parsed two-line element set. Angles are in degrees and mean
+// motion in revolutions per day, as written in the element set.
+type TLE struct {
+	Line1 string
+	Line2 string
+
+	CatalogNumber           int
+	Classification          byte
+	InternationalDesignator string
+	EpochYear               int     // Four digit year
+	EpochDay                float64 // Day of year, 1.0 is midnight on January 1st
+	MeanMotionDot           float64 // First derivative of mean motion / 2, rev/day^2
+	MeanMotionDDot          float64 // Second derivative of mean motion / 6, rev/day^3
+	Bstar                   float64 // 1/earth radii
+	EphemerisType           int
+	ElementSetNumber        int
+
+	Inclination  float64
+	RAAN         float64
+	Eccentricity float64
+	ArgPerigee   float64
+	MeanAnomaly  float64
+	MeanMotion   float64
+	RevNumber    int
+}
+
+// TLEError describes which field of which line could not be parsed
+type TLEError struct {
+	Line    int
+	Field   string
+	Columns [2]int // 1-based and inclusive, zero when the whole line is at fault
+	Message string
+}
+
+func (e *TLEError) Error() string {
+	if e.Columns[0] == 0 {
+		return fmt.Sprintf("TLE line %d %s: %s", e.Line, e.Field, e.Message)
+	}
+	return fmt.Sprintf("TLE line %d %s (columns %d-%d): %s", e.Line, e.Field, e.Columns[0], e.Columns[1], e.Message)
+}
+
+// Columns that must be blank in each line, 1-based
+var tleLine1Blanks = []int{2, 9, 18, 33, 44, 53, 62, 64}
+var tleLine2Blanks = []int{2, 8, 17, 26, 34, 43, 52}
+
+// ParseTLE validates the column layout and checksums of both lines and parses
+// every field. Trailing whitespace and carriage returns are ignored.
+func ParseTLE(line1, line2 string) (*TLE, error) {
+	line1 = strings.TrimRight(line1, " \t\r\n")
+	line2 = strings.TrimRight(line2, " \t\r\n")
+
+	if err := validateTLELine(line1, 1, tleLine1Blanks); err != nil {
+		return nil, err
+	}
+	if err := validateTLELine(line2, 2, tleLine2Blanks); err != nil {
+		return nil, err
+	}
+
+	tle := &TLE{Line1: line1, Line2: line2}
+	p := tleFieldParser{}
+
+	// Line 1
+	p.line, p.text = 1, line1
+	tle.CatalogNumber = p.catalogNumber("catalog number", 3, 7)
+	tle.Classification = line1[7]
+	tle.InternationalDesignator = p.string(10, 17)
+	epochYear := p.int("epoch year", 19, 20)
+	tle.EpochDay = p.float("epoch day", 21, 32)
+	tle.MeanMotionDot = p.float("first derivative of mean motion", 34, 43)
+	tle.MeanMotionDDot = p.impliedDecimal("second derivative of mean motion", 45, 52)
+	tle.Bstar = p.impliedDecimal("bstar", 54, 61)
+	tle.EphemerisType = p.intOrZero("ephemeris type", 63, 63)
+	tle.ElementSetNumber = p.intOrZero("element set number", 65, 68)
+
+	// Line 2
+	p.line, p.text = 2, line2
+	catalogNumber2 := p.catalogNumber("catalog number", 3, 7)
+	tle.Inclination = p.float("inclination", 9, 16)
+	tle.RAAN = p.float("right ascension of ascending node", 18, 25)
+	tle.Eccentricity = p.eccentricity("eccentricity", 27, 33)
+	tle.ArgPerigee = p.float("argument of perigee", 35, 42)
+	tle.MeanAnomaly = p.float("mean anomaly", 44, 51)
+	tle.MeanMotion = p.float("mean motion", 53, 63)
+	tle.RevNumber = p.intOrZero("revolution number", 64, 68)
+
+	if p.err != nil {
+		return nil, p.err
+	}
+
+	if tle.Classification != 'U' && tle.Classification != 'C' && tle.Classification != 'S' {
+		return nil, &TLEError{Line: 1, Field: "classification", Columns: [2]int{8, 8}, Message: fmt.Sprintf("unknown classification %q", tle.Classification)}
+	}
+	if tle.CatalogNumber != catalogNumber2 {
+		return nil, &TLEError{Line: 2, Field: "catalog number", Columns: [2]int{3, 7}, Message: fmt.Sprintf("%d does not match line 1 (%d)", catalogNumber2, tle.CatalogNumber)}
+	}
+	if _, err := parseTLEEpoch(line1[18:32]); err != nil {
+		return nil, &TLEError{Line: 1, Field: "epoch day", Columns: [2]int{21, 32}, Message: fmt.Sprintf("%v is not a day of the year", tle.EpochDay)}
+	}
+	if tle.Inclination < 0 || tle.Inclination > 180 {
+		return nil, &TLEError{Line: 2, Field: "inclination", Columns: [2]int{9, 16}, Message: fmt.Sprintf("%v is outside 0-180 degrees", tle.Inclination)}
+	}
+	if tle.MeanMotion <= 0 {
+		return nil, &TLEError{Line: 2, Field: "mean motion", Columns: [2]int{53, 63}, Message: "must be positive"}
+	}
+
+	tle.EpochYear = tleEpochYear(epochYear)
+
+	return tle, nil
+}
+
+func validateTLELine(line string, lineNumber int, blanks []int) error {
+	if len(line) != tleLineLength {
+		return &TLEError{Line: lineNumber, Field: "length", Message: fmt.Sprintf("expected %d characters, got %d", tleLineLength, len(line))}
+	}
+	if line[0] != byte('0'+lineNumber) {
+		return &TLEError{Line: lineNumber, Field: "line number", Columns: [2]int{1, 1}, Message: fmt.Sprintf("expected %d, got %q", lineNumber, line[0])}
+	}
+	for _, col := range blanks {
+		if line[col-1] != ' ' {
+			return &TLEError{Line: lineNumber, Field: "layout", Columns: [2]int{col, col}, Message: fmt.Sprintf("expected a blank, got %q", line[col-1])}
+		}
+	}
+
+	expected, err := strconv.Atoi(line[68:69])
+	if err != nil {
+		return &TLEError{Line: lineNumber, Field: "checksum", Columns: [2]int{69, 69}, Message: "not a digit"}
+	}
+	if actual := tleChecksum(line); actual != expected {
+		return &TLEError{Line: lineNumber, Field: "checksum", Columns: [2]int{69, 69}, Message: fmt.Sprintf("expected %d, computed %d", expected, actual)}
+	}
+	return nil
+}
+
+// Modulo 10 sum of the digits in columns 1-68, with minus signs counting as 1
+func tleChecksum(line string) int {
+	sum := 0
+	for _, c := range line[:68] {
+		if c >= '0' && c <= '9' {
+			sum += int(c - '0')
+		} else if c == '-' {
+			sum++
+		}
+	}
+	return sum % 10
+}
+
+// Reads fixed column fields from one line, keeping the first error
+type tleFieldParser struct {
+	line int
+	text string
+	err  error
+}
+
+func (p *tleFieldParser) string(start, end int) string {
+	return strings.TrimSpace(p.text[start-1 : end])
+}
+
+func (p *tleFieldParser) fail(field string, start, end int, err error) {
+	if p.err == nil {
+		p.err = &TLEError{Line: p.line, Field: field, Columns: [2]int{start, end}, Message: err.Error()}
+	}
+}
+
+func (p *tleFieldParser) int(field string, start, end int) int {
+	value, err := strconv.Atoi(p.string(start, end))
+	if err != nil {
+		p.fail(field, start, end, fmt.Errorf("invalid integer %q", p.string(start, end)))
+	}
+	return value
+}
+
+func (p *tleFieldParser) catalogNumber(field string, start, end int) int {
+	value, err := parseAlpha5(p.string(start, end))
+	if err != nil {
+		p.fail(field, start, end, err)
+	}
+	return value
+}
+
+func (p *tleFieldParser) intOrZero(field string, start, end int) int {
+	if p.string(start, end) == "" {
+		return 0
+	}
+	return p.int(field, start, end)
+}
+
+func (p *tleFieldParser) float(field string, start, end int) float64 {
+	value, err := strconv.ParseFloat(p.string(start, end), 64)
+	if err != nil {
+		p.fail(field, start, end, fmt.Errorf("invalid number %q", p.string(start, end)))
+	}
+	return value
+}
+
+func (p *tleFieldParser) impliedDecimal(field string, start, end int) float64 {
+	value, err := parseImpliedDecimal(p.string(start, end))
+	if err != nil {
+		p.fail(field, start, end, err)
+	}
+	return value
+}
+
+// Eccentricity has an implied leading decimal point
+func (p *tleFieldParser) eccentricity(field string, start, end int) float64 {
+	text := p.string(start, end)
+	for _, c := range text {
+		if c < '0' || c > '9' {
+			p.fail(field, start, end, fmt.Errorf("invalid eccentricity %q", text))
+			return 0
+		}
+	}
+	value, err := strconv.ParseFloat("."+text, 64)
+	if err != nil {
+		p.fail(field, start, end, fmt.Errorf("invalid eccentricity %q", text))
+	}
+	return value
+}
+
+// EpochJulianDate is the epoch with the day fraction kept as written
+func (t *TLE) EpochJulianDate() JulianDate {
+	return tleEpochToJulianDate(t.EpochYear, t.EpochDay)
+}
+
+// EpochTime is the epoch as a UTC time, rounded to the microsecond
+func (t *TLE) EpochTime() time.Time {
+	start := time.Date(t.EpochYear, 1, 1, 0, 0, 0, 0, time.UTC)
+	offset := time.Duration(math.Round((t.EpochDay - 1) * 86400e6))
+	return start.Add(offset * time.Microsecond)
+}
+
+func (t *TLE) sgp4Elements() sgp4Elements {
+	epoch := t.EpochJulianDate()
+
+	deg2rad := math.Pi / 180.0
+	return sgp4Elements{
+		EpochJD:       epoch.Day,
+		EpochFraction: epoch.Fraction,
+		Bstar:         t.Bstar,
+		Inclination:   t.Inclination * deg2rad,
+		RAAN:          t.RAAN * deg2rad,
+		Eccentricity:  t.Eccentricity,
+		ArgPerigee:    t.ArgPerigee * deg2rad,
+		MeanAnomaly:   t.MeanAnomaly * deg2rad,
+		MeanMotion:    t.MeanMotion / xpdotp,
+	}
+}
+
+func sgp4ElementsFromTLE(tle1, tle2 string) (sgp4Elements, error) {
+	tle, err := ParseTLE(tle1, tle2)
+	if err != nil {
+		return sgp4Elements{}, err
+	}
+	return tle.sgp4Elements(), nil
+}
+
+// Alpha-5 prefixes, I and O are skipped to avoid confusion with 1 and 0
+const alpha5Letters = "ABCDEFGHJKLMNPQRSTUVWXYZ"
+
+// Parses a 5 character TLE catalog number. Numbers from 100000 to 339999 use
+// the Alpha-5 scheme where the leading letter stands for 10-33, so "A0001" is
+// 100001.
+func parseAlpha5(text string) (int, error) {
+	if text == "" {
+		return 0, fmt.Errorf("empty catalog number")
+	}
+
+	digits := text
+	prefix := 0
+	if index := strings.IndexByte(alpha5Letters, text[0]); index >= 0 {
+		prefix = 10 + index
+		digits = text[1:]
+	}
+
+	value, err := strconv.Atoi(digits)
+	if err != nil || value < 0 || (prefix > 0 && len(digits) != 4) {
+		return 0, fmt.Errorf("invalid catalog number %q", text)
+	}
+	return prefix*10000 + value, nil
+}
+
+// Decimal catalog number from columns 3-7 of line 1, with Alpha-5 expanded
+func tleCatalogNumber(tle1 string) string {
+	if len(tle1) < 7 {
+		return ""
+	}
+
+	catalogNumber, err := parseAlpha5(strings.TrimSpace(tle1[2:7]))
+	if err != nil {
+		return strings.TrimSpace(tle1[2:7])
+	}
+	return strconv.Itoa(catalogNumber)
+}
+
+// Parses TLE fields such as "+10327-1" or " 24954-3" that mean 0.10327e-1
+func parseImpliedDecimal(text string) (float64, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return 0, nil
+	}
+
+	sign := ""
+	if text[0] == '-' || text[0] == '+' {
+		sign = text[:1]
+		text = text[1:]
+	}
+	if len(text) < 2 {
+		return 0, fmt.Errorf("invalid value %q", text)
+	}
+
+	mantissa := text[:len(text)-2]
+	exponent := text[len(text)-2:]
+	value, err := strconv.ParseFloat(sign+"0."+strings.TrimSpace(mantissa)+"e"+exponent, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q", sign+text)
+	}
+	return value, nil
+}
+
+// ReadTLEText reads a plain text catalog of element sets. Each set can be
+// two lines (2LE) or have a name line in front (3LE, with or without the
+// "0 " prefix), and the two styles may be mixed. Blank lines, CRLF endings and
+// trailing whitespace are ignored. A set with a missing line is rejected and
+// reading carries on from the next line 1.
+func ReadTLEText(r io.Reader) ([]SatelliteApiData, []RejectedSatellite, error) {
+	type numberedLine struct {
+		number int
+		text   string
+	}
+
+	lines := []numberedLine{}
+	scanner := bufio.NewScanner(r)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		text := strings.TrimRight(scanner.Text(), " \t\r")
+		if text != "" {
+			lines = append(lines, numberedLine{number: lineNumber, text: text})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	isLine := func(i int, lineNumber byte) bool {
+		return i < len(lines) && len(lines[i].text) > 1 && lines[i].text[0] == lineNumber && lines[i].text[1] == ' '
+	}
+
+	satellitesData := []SatelliteApiData{}
+	rejected := []RejectedSatellite{}
+	for i := 0; i < len(lines); {
+		name := ""
+		if !isLine(i, '1') {
+			name = strings.TrimSpace(strings.TrimPrefix(lines[i].text, "0 "))
+			i++
+		}
+
+		if !isLine(i, '1') || !isLine(i+1, '2') {
+			// The first line of the set that is not where it should be
+			missing := 1
+			if isLine(i, '1') {
+				missing = 2
+				name = tleSetName(name, lines[i].text)
+				i++
+			}
+			err := &TLEError{Line: missing, Field: "missing", Message: "at end of file"}
+			if i < len(lines) {
+				err.Message = fmt.Sprintf("line %d of the file is not TLE line %d", lines[i].number, missing)
+			}
+			rejected = append(rejected, RejectedSatellite{ObjectID: name, Err: err})
+
+			// Resume at the next line 1, or the name line in front of it
+			for i < len(lines) && !isLine(i, '1') && (isLine(i, '2') || !isLine(i+1, '1')) {
+				i++
+			}
+			continue
+		}
+
+		line1, line2 := lines[i].text, lines[i+1].text
+		i += 2
+
+		record := SatelliteApiData{
+			ObjectName: name,
+			TLE_1:      line1,
+			TLE_2:      line2,
+		}
+		if len(line1) >= 17 {
+			record.ObjectID = tleObjectID(strings.TrimSpace(line1[9:17]))
+		}
+		if catalogNumber, err := parseAlpha5(strings.TrimSpace(line1[2:min(7, len(line1))])); err == nil {
+			record.NoradCatID = CatalogNumber(catalogNumber)
+		}
+		satellitesData = append(satellitesData, record)
+	}
+
+	return satellitesData, rejected, nil
+}
+
+// Names a rejected set by its name line, or the designator of its line 1
+func tleSetName(name, line1 string) string {
+	if name == "" && len(line1) >= 17 {
+		return tleObjectID(strings.TrimSpace(line1[9:17]))
+	}
+	return name
+}
+
+// Converts a TLE international designator such as "98067A" to the
+// Space-Track OBJECT_ID form "1998-067A"
+func tleObjectID(designator string) string {
+	if len(designator) < 5 {
+		return designator
+	}
+
+	year, err := strconv.Atoi(designator[:2])
+	if err != nil {
+		return designator
+	}
+	if year < 57 {
+		year += 2000
+	} else {
+		year += 1900
+	}
+	return fmt.Sprintf("%d-%s", year, designator[2:])
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTLEFields(t *testing.T) {
+	tle, err := ParseTLE(SatOneLineOne, SatOneLineTwo)
+	assert.NoError(t, err)
+
+	assert.Equal(t, 84232, tle.CatalogNumber)
+	assert.Equal(t, byte('U'), tle.Classification)
+	assert.Equal(t, "79104", tle.InternationalDesignator)
+	assert.Equal(t, 2025, tle.EpochYear)
+	assert.Equal(t, 11.29418726, tle.EpochDay)
+	assert.Equal(t, 0.00010894, tle.MeanMotionDot)
+	assert.Equal(t, 0.0, tle.MeanMotionDDot)
+	assert.InDelta(t, 0.010327, tle.Bstar, 1e-12)
+	assert.Equal(t, 999, tle.ElementSetNumber)
+	assert.Equal(t, 20.2440, tle.Inclination)
+	assert.Equal(t, 103.5465, tle.RAAN)
+	assert.Equal(t, 0.6615434, tle.Eccentricity)
+	assert.Equal(t, 81.8936, tle.ArgPerigee)
+	assert.Equal(t, 342.8433, tle.MeanAnomaly)
+	assert.Equal(t, 3.09154996, tle.MeanMotion)
+	assert.Equal(t, 9416, tle.RevNumber)
+
+	epoch := tle.EpochJulianDate()
+	assert.Equal(t, 2460686.5, epoch.Day)
+	assert.InDelta(t, 0.29418726, epoch.Fraction, 1e-12)
+}
+
+func TestParseTLEAcceptsTrailingWhitespace(t *testing.T) {
+	_, err := ParseTLE(SatTwoLineOne+"  \r\n", SatTwoLineTwo+"\r")
+	assert.NoError(t, err)
+}
+
+func TestParseTLEErrors(t *testing.T) {
+	badChecksum := SatTwoLineOne[:68] + "0"
+	shifted := SatTwoLineTwo[:16] + "0" + SatTwoLineTwo[17:]
+	mismatched := strings.Replace(SatThreeLineTwo, "58247", "58248", 1)
+	mismatched = mismatched[:68] + strconv.Itoa(tleChecksum(mismatched))
+
+	cases := []struct {
+		line1, line2 string
+		line         int
+		field        string
+	}{
+		{badChecksum, SatTwoLineTwo, 1, "checksum"},
+		{SatTwoLineOne[:60], SatTwoLineTwo, 1, "length"},
+		{SatTwoLineTwo, SatTwoLineOne, 1, "line number"},
+		{SatTwoLineOne, shifted, 2, "layout"},
+		{SatThreeLineOne, mismatched, 2, "catalog number"},
+	}
+
+	for _, c := range cases {
+		_, err := ParseTLE(c.line1, c.line2)
+
+		var tleErr *TLEError
+		if assert.ErrorAs(t, err, &tleErr, c.field) {
+			assert.Equal(t, c.line, tleErr.Line, c.field)
+			assert.Equal(t, c.field, tleErr.Field)
+		}
+	}
+}
+
+func withChecksum(line string) string {
+	return line[:68] + strconv.Itoa(tleChecksum(line))
+}
+
+func TestParseTLEAlpha5CatalogNumber(t *testing.T) {
+	line1 := withChecksum(strings.Replace(SatTwoLineOne, "56700", "A0001", 1))
+	line2 := withChecksum(strings.Replace(SatTwoLineTwo, "56700", "A0001", 1))
+
+	tle, err := ParseTLE(line1, line2)
+	assert.NoError(t, err)
+	assert.Equal(t, 100001, tle.CatalogNumber)
+
+	satellite, err := NewSgp4Satellite(line1, line2)
+	assert.NoError(t, err)
+	assert.Equal(t, "100001", satellite.satelliteID())
+
+	for text, expected := range map[string]int{"00005": 5, "Z9999": 339999, "J0000": 180000, "P1234": 231234} {
+		value, err := parseAlpha5(text)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, value, text)
+	}
+
+	for _, text := range []string{"I0001", "O0001", "A001", "a0001"} {
+		_, err := parseAlpha5(text)
+		assert.Error(t, err, text)
+	}
+}
+
+func TestReadTLETextMixed3LEAnd2LE(t *testing.T) {
+	text := "0 VANGUARD 1  \r\n" + SatOneLineOne + "\r\n" + SatOneLineTwo + "   \r\n" +
+		"\r\n" +
+		SatTwoLineOne + "\r\n" + SatTwoLineTwo + "\r\n" +
+		"STARLINK-30000\n" + SatThreeLineOne + "\n" + SatThreeLineTwo
+
+	path := filepath.Join(t.TempDir(), "catalog.txt")
+	assert.NoError(t, os.WriteFile(path, []byte(text), 0o644))
+
+	records, _, err := loadCatalogFile(path)
+	assert.NoError(t, err)
+	assert.Len(t, records, 3)
+
+	assert.Equal(t, "VANGUARD 1", records[0].ObjectName)
+	assert.Equal(t, "1979-104", records[0].ObjectID)
+	assert.Equal(t, CatalogNumber(84232), records[0].NoradCatID)
+	assert.Equal(t, "", records[1].ObjectName)
+	assert.Equal(t, "2023-067N", records[1].ObjectID)
+	assert.Equal(t, "STARLINK-30000", records[2].ObjectName)
+
+	for _, record := range records {
+		_, err := newSatelliteFromRecord(record)
+		assert.NoError(t, err)
+	}
+}
+
+func TestReadTLETextSkipsMalformedSets(t *testing.T) {
+	text := "NAME\n" + SatOneLineOne + "\n" +
+		"NEXT\n" + SatTwoLineOne + "\n" + SatTwoLineTwo + "\n" +
+		"ORPHAN\n" + SatThreeLineTwo + "\n" +
+		SatThreeLineOne + "\n" + SatThreeLineTwo + "\n" +
+		SatOneLineOne + "\n"
+
+	records, rejected, err := ReadTLEText(strings.NewReader(text))
+	assert.NoError(t, err)
+	assert.Len(t, records, 2)
+	assert.Equal(t, "NEXT", records[0].ObjectName)
+	assert.Equal(t, "2023-171T", records[1].ObjectID)
+
+	assert.Len(t, rejected, 3)
+	assert.Equal(t, "NAME", rejected[0].ObjectID)
+	assert.EqualError(t, rejected[0].Err, "TLE line 2 missing: line 3 of the file is not TLE line 2")
+	assert.Equal(t, "ORPHAN", rejected[1].ObjectID)
+	assert.EqualError(t, rejected[1].Err, "TLE line 1 missing: line 7 of the file is not TLE line 1")
+	assert.Equal(t, "1979-104", rejected[2].ObjectID)
+	assert.EqualError(t, rejected[2].Err, "TLE line 2 missing: at end of file")
+
+	var tleErr *TLEError
+	assert.ErrorAs(t, rejected[0].Err, &tleErr)
+}